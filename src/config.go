@@ -18,19 +18,23 @@ type DBConfig struct {
     Host     string `json:"host"`
     Port     string `json:"port"`
     Name     string `json:"name"`
+    Driver   string `json:"driver,omitempty"` // mysql (default, version detected), mysql57, mysql8, mariadb, sqlite or postgres
 }
 
 // PathConfig holds file system paths
 type PathConfig struct {
-    Base   string `json:"base"`   // path to base DBC files
-    Export string `json:"export"` // path to DBC export directory
-    Meta   string `json:"meta"`   // path to meta files
+    Base   string   `json:"base"`          // path to base DBC files
+    Export string   `json:"export"`        // path to DBC export directory
+    Meta   string   `json:"meta"`          // path to meta files
+    MPQ    []string `json:"mpq,omitempty"` // client MPQ archives (globs allowed), lowest precedence first
 }
 
 // OptionConfig holds generic import/export options
 type OptionConfig struct {
     UseVersioning      bool `json:"use_versioning"`         // whether or not to use DBC export versioning
     UseLowercaseTables bool `json:"use_lowercase_tables"`   // whether or not to use lowercase database table names
+    ForeignKeys        bool `json:"foreign_keys"`           // whether or not to create foreign keys from meta refs
+    FloatStorage       string `json:"float_storage"`        // SQL storage of float fields: decimal, double or bits
 }
 
 // Config is the root config.json structure
@@ -38,6 +42,20 @@ type Config struct {
     DBC     DBConfig     `json:"dbc"`
     Paths   PathConfig   `json:"paths"`
     Options OptionConfig `json:"options"`
+
+    mpqSet *MPQSet // opened lazily by archives()
+}
+
+// archives opens the configured MPQ patch chain on first use
+func (c *Config) archives() (*MPQSet, error) {
+    if c.mpqSet == nil {
+        set, err := OpenMPQSet(c.Paths.MPQ)
+        if err != nil {
+            return nil, err
+        }
+        c.mpqSet = set
+    }
+    return c.mpqSet, nil
 }
 
 // loadOrInitConfig loads config.json, or generates a template if missing
@@ -45,7 +63,7 @@ func loadOrInitConfig(path string) (*Config, bool, error) {
     if _, err := os.Stat(path); os.IsNotExist(err) {
         // Create template config
         template := Config{
-            DBC: DBConfig{"root", "password", "127.0.0.1", "3306", "dbc"},
+            DBC: DBConfig{"root", "password", "127.0.0.1", "3306", "dbc", "mysql"},
             Paths: PathConfig{
                 Base:   "./dbc_files",
                 Export: "./dbc_export",
@@ -54,6 +72,8 @@ func loadOrInitConfig(path string) (*Config, bool, error) {
             Options: OptionConfig{
                 UseVersioning: false,
                 UseLowercaseTables: false,
+                ForeignKeys: false,
+                FloatStorage: "decimal",
             },
         }
 
@@ -80,5 +100,13 @@ func loadOrInitConfig(path string) (*Config, bool, error) {
     if err := json.NewDecoder(file).Decode(&cfg); err != nil {
         return nil, false, fmt.Errorf("decode config: %w", err)
     }
+
+    switch cfg.Options.FloatStorage {
+    case "":
+        cfg.Options.FloatStorage = "decimal"
+    case "decimal", "double", "bits":
+    default:
+        return nil, false, fmt.Errorf("invalid options.float_storage %q: expected decimal, double or bits", cfg.Options.FloatStorage)
+    }
     return &cfg, false, nil
 }
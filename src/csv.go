@@ -0,0 +1,148 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// csvCR stands in for carriage returns in CSV strings: encoding/csv drops a \r
+// before \n even inside quoted fields, so CRLF would not survive a round trip
+const csvCR = "\u240D" // ␍
+
+// WriteCSV writes a table as CSV with one column per schema column, named as in SQL.
+// Strings are written as text with carriage returns replaced by csvCR.
+func WriteCSV(w io.Writer, dbc *DBCFile) error {
+    out := csv.NewWriter(w)
+    schema := dbc.Table.Schema
+
+    header := make([]string, len(schema.Columns))
+    for c, col := range schema.Columns {
+        header[c] = col.Name
+    }
+    if err := out.Write(header); err != nil {
+        return err
+    }
+
+    record := make([]string, len(schema.Columns))
+    for row := 0; row < dbc.Table.Len(); row++ {
+        for c, col := range schema.Columns {
+            record[c] = formatCell(dbc, row, c)
+            if col.Type != "string" {
+                continue
+            }
+            if strings.Contains(record[c], csvCR) {
+                return fmt.Errorf("record %d, column %s: the text contains %s, which CSV uses for carriage returns", row, col.Name, csvCR)
+            }
+            record[c] = strings.ReplaceAll(record[c], "\r", csvCR)
+        }
+        if err := out.Write(record); err != nil {
+            return err
+        }
+    }
+
+    out.Flush()
+    return out.Error()
+}
+
+// formatCell renders a value as text that parseCell reads back unchanged
+func formatCell(dbc *DBCFile, row, c int) string {
+    switch dbc.Table.Schema.Columns[c].Type {
+    case "string":
+        return dbc.Text(row, c)
+    case "float":
+        return strconv.FormatFloat(float64(dbc.Table.Float(row, c)), 'g', -1, 32)
+    case "int8", "int16", "int32", "int64":
+        return strconv.FormatInt(dbc.Table.Int(row, c), 10)
+    default:
+        return strconv.FormatUint(dbc.Table.Raw(row, c), 10)
+    }
+}
+
+// ReadCSV reads a CSV written by WriteCSV, or edited from one, into a table.
+// Columns are matched by header name in any order; missing columns are zero.
+func ReadCSV(r io.Reader, schema *Schema) (*DBCFile, error) {
+    in := csv.NewReader(r)
+    header, err := in.Read()
+    if err != nil {
+        return nil, fmt.Errorf("failed to read CSV header: %w", err)
+    }
+    in.FieldsPerRecord = len(header)
+
+    colIndex := resultColumns(schema, header)
+    for i, name := range header {
+        if _, ok := schema.Lookup(name); !ok {
+            return nil, fmt.Errorf("CSV column %d %q is not a column of the meta", i+1, name)
+        }
+    }
+
+    dbc := &DBCFile{Table: NewTable(schema, 0), StringBlock: []byte{0}}
+    copy(dbc.Header.Magic[:], schema.Meta.FileFormat())
+    offsets := map[string]uint32{"": 0}
+
+    for line := 2; ; line++ {
+        record, err := in.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        row := dbc.Table.AppendRow()
+        for c, col := range schema.Columns {
+            if colIndex[c] < 0 {
+                continue
+            }
+            text := record[colIndex[c]]
+            if col.Type == "string" {
+                text = strings.ReplaceAll(text, csvCR, "\r")
+                dbc.Table.SetRaw(row, c, uint64(getStringOffset(text, &dbc.StringBlock, offsets)))
+                continue
+            }
+            bits, err := parseCell(col, text)
+            if err != nil {
+                return nil, fmt.Errorf("line %d, column %s: %w", line, col.Name, err)
+            }
+            dbc.Table.SetRaw(row, c, bits)
+        }
+    }
+
+    return dbc, nil
+}
+
+// parseCell parses the text of a non-string value into its raw bits.
+// An empty cell is zero.
+func parseCell(col Column, text string) (uint64, error) {
+    if text == "" {
+        return 0, nil
+    }
+    switch col.Type {
+    case "float":
+        f, err := strconv.ParseFloat(text, 32)
+        if err != nil {
+            return 0, err
+        }
+        return uint64(math.Float32bits(float32(f))), nil
+    case "int8", "int16", "int32", "int64":
+        n, err := strconv.ParseInt(text, 10, col.Size*8)
+        if err != nil {
+            return 0, err
+        }
+        return uint64(n), nil
+    default:
+        n, err := strconv.ParseUint(text, 10, col.Size*8)
+        if err != nil {
+            return 0, err
+        }
+        return n, nil
+    }
+}
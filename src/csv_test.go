@@ -0,0 +1,61 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+// csvTestDBC builds a table of ids and names
+func csvTestDBC(t *testing.T, names ...string) *DBCFile {
+    t.Helper()
+    meta := &MetaFile{File: "Test.dbc", PrimaryKeys: []string{"id"}, Fields: []FieldMeta{
+        {Name: "id", Type: "uint32"},
+        {Name: "name", Type: "string"},
+    }}
+    schema, err := NewSchema(meta)
+    if err != nil {
+        t.Fatal(err)
+    }
+    dbc := &DBCFile{Table: NewTable(schema, len(names))}
+    strs := newStringTable(nil)
+    for i, name := range names {
+        row := dbc.Table.AppendRow()
+        dbc.Table.SetRaw(row, 0, uint64(i+1))
+        dbc.Table.SetRaw(row, 1, uint64(strs.offset(name)))
+    }
+    dbc.StringBlock = strs.block
+    return dbc
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+    names := []string{"plain", "a\r\nb", "cr\ronly", "lf\nonly", `quote "and", comma`, ""}
+    var buf bytes.Buffer
+    if err := WriteCSV(&buf, csvTestDBC(t, names...)); err != nil {
+        t.Fatal(err)
+    }
+    dbc, err := ReadCSV(&buf, csvTestDBC(t).Table.Schema)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if dbc.Table.Len() != len(names) {
+        t.Fatalf("read %d records, want %d", dbc.Table.Len(), len(names))
+    }
+    for row, want := range names {
+        if got := dbc.Text(row, 1); got != want {
+            t.Errorf("record %d: name = %q, want %q", row, got, want)
+        }
+    }
+}
+
+func TestCSVRejectsCRSymbol(t *testing.T) {
+    err := WriteCSV(&bytes.Buffer{}, csvTestDBC(t, "ok", "uses "+csvCR))
+    if err == nil || !strings.Contains(err.Error(), "record 1, column name") {
+        t.Errorf("got error %v, want one naming record 1, column name", err)
+    }
+}
@@ -8,21 +8,95 @@ package main
 import (
     "database/sql"
     "fmt"
+    "strings"
 
     _ "github.com/go-sql-driver/mysql"
+    _ "github.com/lib/pq"
+    _ "modernc.org/sqlite"
 )
 
 // DBConnections holds open database connections
 type DBConnections struct {
-    DBC *sql.DB
+    DBC *DB
 }
 
-// openDB opens a database connection from DBConfig
-func openDB(c DBConfig) (*sql.DB, error) {
-    dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
-        c.User, c.Password, c.Host, c.Port, c.Name)
+// DB is an open database and the dialect of its backend.
+// Queries are written with ? placeholders and rebound for the driver.
+type DB struct {
+    *sql.DB
+    Dialect Dialect
+}
+
+// Exec runs a statement with ? placeholders
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return db.DB.Exec(db.Dialect.Rebind(query), args...)
+}
+
+// Query runs a query with ? placeholders
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+    return db.DB.Query(db.Dialect.Rebind(query), args...)
+}
+
+// QueryRow runs a single row query with ? placeholders
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+    return db.DB.QueryRow(db.Dialect.Rebind(query), args...)
+}
 
-    db, err := sql.Open("mysql", dsn)
+// Begin starts a transaction that rebinds placeholders like DB
+func (db *DB) Begin() (*Tx, error) {
+    tx, err := db.DB.Begin()
+    if err != nil {
+        return nil, err
+    }
+    return &Tx{Tx: tx, dialect: db.Dialect}, nil
+}
+
+// Tx is a transaction on a DB
+type Tx struct {
+    *sql.Tx
+    dialect Dialect
+}
+
+// Exec runs a statement with ? placeholders
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+    return tx.Tx.Exec(tx.dialect.Rebind(query), args...)
+}
+
+// execer runs statements; it is satisfied by *DB, *Tx and sqlDump
+type execer interface {
+    Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// openDB opens a database connection from DBConfig.
+// For the sqlite driver, Name is the path of the database file.
+// With foreignKeys, MySQL sessions skip foreign key checks so a table may
+// reference one that is imported later.
+func openDB(c DBConfig, foreignKeys bool) (*DB, error) {
+    dialect, err := dialectFor(c.Driver)
+    if err != nil {
+        return nil, err
+    }
+
+    var db *sql.DB
+    switch dialect.Name() {
+    case "sqlite":
+        db, err = sql.Open("sqlite", c.Name)
+        // one connection, so a transaction never waits on the database lock held by another
+        if err == nil {
+            db.SetMaxOpenConns(1)
+        }
+    case "postgres":
+        dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+            pqValue(c.Host), pqValue(c.Port), pqValue(c.User), pqValue(c.Password), pqValue(c.Name))
+        db, err = sql.Open("postgres", dsn)
+    default:
+        dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
+            c.User, c.Password, c.Host, c.Port, c.Name)
+        if foreignKeys {
+            dsn += "&foreign_key_checks=0"
+        }
+        db, err = sql.Open("mysql", dsn)
+    }
     if err != nil {
         return nil, fmt.Errorf("open db: %w", err)
     }
@@ -31,5 +105,16 @@ func openDB(c DBConfig) (*sql.DB, error) {
         return nil, fmt.Errorf("ping db: %w", err)
     }
 
-    return db, nil
+    if d := strings.ToLower(c.Driver); d == "" || d == "mysql" {
+        if dialect, err = detectMySQLDialect(db); err != nil {
+            return nil, fmt.Errorf("detect server version: %w", err)
+        }
+    }
+
+    return &DB{DB: db, Dialect: dialect}, nil
+}
+
+// pqValue quotes a value for a key=value connection string
+func pqValue(v string) string {
+    return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
 }
@@ -9,6 +9,7 @@ import (
     "database/sql"
     "fmt"
     "log"
+    "math"
     "os"
     "path/filepath"
     "strconv"
@@ -16,7 +17,7 @@ import (
 )
 
 // ExportDBCs iterates over all meta files and exports each table
-func ExportDBCs(db *sql.DB, cfg *Config) error {
+func ExportDBCs(db *DB, cfg *Config) error {
     metas, err := filepath.Glob(filepath.Join(cfg.Paths.Meta, "*.meta.json"))
     if err != nil {
         return fmt.Errorf("failed to scan meta directory: %w", err)
@@ -32,23 +33,16 @@ func ExportDBCs(db *sql.DB, cfg *Config) error {
 }
 
 // ExportDBC handles exporting a single table/meta to a DBC file
-func ExportDBC(db *sql.DB, cfg *Config, metaPath string) error {
+func ExportDBC(db *DB, cfg *Config, metaPath string) error {
     meta, err := LoadMeta(metaPath)
     if err != nil {
         return fmt.Errorf("failed to load meta %s: %w", metaPath, err)
     }
     
-    tableName := strings.TrimSuffix(meta.File, ".dbc")
-    if meta.TableName != "" {
-        tableName = meta.TableName
-    }
-    
-    if cfg.Options.UseLowercaseTables {
-        tableName = strings.ToLower(strings.TrimSpace(tableName))
-    }
+    tableName := resolveTableName(&meta, cfg)
     
     // Ensure checksum table & entry exist
-    if err := ensureChecksumTable(db); err != nil {
+    if err := ensureChecksumTable(db, db.Dialect); err != nil {
         return fmt.Errorf("failed to ensure dbc_checksum table: %w", err)
     }
     
@@ -74,9 +68,25 @@ func ExportDBC(db *sql.DB, cfg *Config, metaPath string) error {
     
     log.Printf("Exporting table %s to DBC...\n", tableName)
     
-    orderClause := buildOrderBy(meta.SortOrder)
+    schema, err := NewSchema(&meta)
+    if err != nil {
+        return fmt.Errorf("invalid meta %s: %w", metaPath, err)
+    }
+
+    var magic [4]byte
+    copy(magic[:], meta.FileFormat())
+    header := DBCHeader{Magic: magic}
+    var prev *DBCFile
+    if meta.FileFormat() == FormatWDB2 {
+        header, prev, err = wdb2HeaderTemplate(db, cfg, tableName, &meta, schema)
+        if err != nil {
+            return fmt.Errorf("failed to build WDB2 header for %s: %w", tableName, err)
+        }
+    }
+
+    orderClause := buildOrderBy(db.Dialect, meta.SortOrder)
     
-    rows, err := db.Query(fmt.Sprintf("SELECT * FROM `%s`%s", tableName, orderClause))
+    rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s%s", db.Dialect.Quote(tableName), orderClause))
     if err != nil {
         return fmt.Errorf("failed to query table %s: %w", tableName, err)
     }
@@ -87,74 +97,48 @@ func ExportDBC(db *sql.DB, cfg *Config, metaPath string) error {
         return fmt.Errorf("failed to get columns for table %s: %w", tableName, err)
     }
 
-    dbc := DBCFile{
-        Header:      DBCHeader{Magic: [4]byte{'W', 'D', 'B', 'C'}},
-        Records:     []Record{},
-        StringBlock: []byte{0}, // first byte must be null
+    reader := newColumnReader(schema, cols)
+
+    outPath := filepath.Join(cfg.Paths.Export, meta.File)
+    if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+        return fmt.Errorf("failed to create export directory: %w", err)
+    }
+
+    // records are written as they are scanned; only the string block is kept in memory
+    w, err := NewDBCWriter(outPath, schema, header, prev)
+    if err != nil {
+        return fmt.Errorf("failed to create DBC %s: %w", outPath, err)
+    }
+    defer w.Abort()
+
+    raw := make([]interface{}, len(cols))
+    ptrs := make([]interface{}, len(cols))
+    for i := range raw {
+        ptrs[i] = &raw[i]
     }
-    stringOffsets := map[string]uint32{"": 0}
 
     for rows.Next() {
-        raw := make([]interface{}, len(cols))
-        ptrs := make([]interface{}, len(cols))
-        for i := range raw {
-            ptrs[i] = &raw[i]
-        }
         if err := rows.Scan(ptrs...); err != nil {
             return fmt.Errorf("failed to scan row for table %s: %w", tableName, err)
         }
 
-        rec := make(Record)
-        for _, field := range meta.Fields {
-            repeat := int(field.Count)
-            if repeat == 0 {
-                repeat = 1
-            }
-
-            for j := 0; j < repeat; j++ {
-                name := field.Name
-                if field.Count > 1 {
-                    name = fmt.Sprintf("%s_%d", field.Name, j+1)
-                }
-
-                switch field.Type {
-                case "int32":
-                    rec[name] = toInt32(raw, cols, name)
-                case "uint32":
-                    rec[name] = toUint32(raw, cols, name)
-                case "uint8":
-                    rec[name] = toUint8(raw, cols, name)
-                case "float":
-                    rec[name] = toFloat32(raw, cols, name)
-                case "string":
-                    str := toString(raw, cols, name)
-                    rec[name] = getStringOffset(str, &dbc.StringBlock, stringOffsets)
-                case "Loc":
-                    loc := make([]uint32, 17)
-                    for i := 0; i < 16; i++ {
-                        colName := fmt.Sprintf("%s_%s", name, locLangs[i])
-                        str := toString(raw, cols, colName)
-                        loc[i] = getStringOffset(str, &dbc.StringBlock, stringOffsets)
-                    }
-                    loc[16] = toUint32(raw, cols, fmt.Sprintf("%s_flags", name))
-                    rec[name] = loc
-                }
+        rec := w.Record()
+        for c, col := range schema.Columns {
+            if bits, ok := reader.value(raw, c, w.AddString); ok {
+                putColumn(rec, col, bits)
             }
         }
-        dbc.Records = append(dbc.Records, rec)
+        if err := w.WriteRecord(); err != nil {
+            return fmt.Errorf("failed to write DBC %s: %w", outPath, err)
+        }
     }
-
-    dbc.Header.RecordCount = uint32(len(dbc.Records))
-    dbc.Header.FieldCount = calculateFieldCount(meta)
-    dbc.Header.RecordSize = calculateRecordSize(meta)
-    dbc.Header.StringBlockSize = uint32(len(dbc.StringBlock))
-
-    outPath := filepath.Join(cfg.Paths.Export, meta.File)
-    if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
-        return fmt.Errorf("failed to create export directory: %w", err)
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("failed to read rows for table %s: %w", tableName, err)
     }
 
-    if err := WriteDBC(&dbc, &meta, outPath); err != nil {
+    reader.report(tableName)
+
+    if err := w.Close(); err != nil {
         return fmt.Errorf("failed to write DBC %s: %w", outPath, err)
     }
     
@@ -168,172 +152,356 @@ func ExportDBC(db *sql.DB, cfg *Config, metaPath string) error {
 
 // --- Helpers ---
 
-func buildOrderBy(sort []SortField) string {
-    if len(sort) == 0 {
-        return ""
+// wdb2HeaderTemplate restores the WDB2 header fields saved at import and reserves the id range
+// currently in the table. Tables imported before the header was stored fall back to the
+// header of the base file.
+func wdb2HeaderTemplate(db *DB, cfg *Config, tableName string, meta *MetaFile, schema *Schema) (DBCHeader, *DBCFile, error) {
+    prev, ok, err := loadStoredHeader(db, tableName)
+    if err != nil {
+        return DBCHeader{}, nil, err
     }
-    parts := make([]string, len(sort))
-    for i, sf := range sort {
-        dir := strings.ToUpper(sf.Direction)
-        if dir != "ASC" && dir != "DESC" {
-            dir = "ASC"
+    if !ok {
+        data, _, err := readDBCData(cfg, meta.File)
+        if err != nil {
+            return DBCHeader{}, nil, fmt.Errorf("no stored header and no base file: %w", err)
+        }
+        header, err := ParseHeader(data)
+        if err != nil {
+            return DBCHeader{}, nil, err
         }
-        parts[i] = fmt.Sprintf("`%s` %s", sf.Name, dir)
+        log.Printf("No stored header for %s; using header of base file", tableName)
+        prev = &DBCFile{Header: header}
     }
-    return " ORDER BY " + strings.Join(parts, ", ")
-}
 
-func getStringOffset(s string, block *[]byte, offsets map[string]uint32) uint32 {
-    if off, ok := offsets[s]; ok {
-        return off
+    header := DBCHeader{
+        Magic:     [4]byte{'W', 'D', 'B', '2'},
+        TableHash: prev.Header.TableHash,
+        Build:     prev.Header.Build,
+        Timestamp: prev.Header.Timestamp,
+        Locale:    prev.Header.Locale,
     }
-    off := uint32(len(*block))
-    *block = append(*block, []byte(s)...)
-    *block = append(*block, 0)
-    offsets[s] = off
-    return off
-}
 
-func calculateRecordSize(meta MetaFile) uint32 {
-    size := 0
-    for _, f := range meta.Fields {
-        repeat := int(f.Count)
-        if repeat == 0 {
-            repeat = 1
+    if prev.Header.MaxID != 0 && len(schema.Columns) > 0 {
+        var minID, maxID sql.NullInt64
+        idCol := db.Dialect.Quote(schema.Columns[0].Name)
+        query := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", idCol, idCol, db.Dialect.Quote(tableName))
+        if err := db.QueryRow(query).Scan(&minID, &maxID); err != nil {
+            return DBCHeader{}, nil, fmt.Errorf("failed to query id range: %w", err)
+        }
+        if minID.Valid && maxID.Valid {
+            header.MinID, header.MaxID = uint32(minID.Int64), uint32(maxID.Int64)
         }
+    }
+
+    return header, prev, nil
+}
 
-        for j := 0; j < repeat; j++ {
-            switch f.Type {
-            case "int32", "uint32", "float", "string":
-                size += 4
-            case "uint8":
-                size += 1
-            case "Loc":
-                size += 4 * 17
+// LoadTable reads a table into memory as a DBC file, ordered like an export
+func LoadTable(db *DB, tableName string, meta MetaFile) (*DBCFile, error) {
+    schema, err := NewSchema(&meta)
+    if err != nil {
+        return nil, err
+    }
+
+    rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s%s", db.Dialect.Quote(tableName), buildOrderBy(db.Dialect, meta.SortOrder)))
+    if err != nil {
+        return nil, fmt.Errorf("failed to query table %s: %w", tableName, err)
+    }
+    defer rows.Close()
+
+    cols, err := rows.Columns()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get columns for table %s: %w", tableName, err)
+    }
+    reader := newColumnReader(schema, cols)
+
+    dbc := &DBCFile{Table: NewTable(schema, 0), StringBlock: []byte{0}}
+    copy(dbc.Header.Magic[:], meta.FileFormat())
+    offsets := map[string]uint32{"": 0}
+    addString := func(s string) uint32 {
+        return getStringOffset(s, &dbc.StringBlock, offsets)
+    }
+
+    raw := make([]interface{}, len(cols))
+    ptrs := make([]interface{}, len(cols))
+    for i := range raw {
+        ptrs[i] = &raw[i]
+    }
+    for rows.Next() {
+        if err := rows.Scan(ptrs...); err != nil {
+            return nil, fmt.Errorf("failed to scan row for table %s: %w", tableName, err)
+        }
+        row := dbc.Table.AppendRow()
+        for c := range schema.Columns {
+            if bits, ok := reader.value(raw, c, addString); ok {
+                dbc.Table.SetRaw(row, c, bits)
             }
         }
     }
-    return uint32(size)
-}
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("failed to read rows for table %s: %w", tableName, err)
+    }
+    reader.report(tableName)
 
-func calculateFieldCount(meta MetaFile) uint32 {
-    count := 0
-    for _, f := range meta.Fields {
-        repeat := int(f.Count)
-        if repeat == 0 {
-            repeat = 1
+    var prev *DBCFile
+    if meta.FileFormat() == FormatWDB2 {
+        stored, ok, err := loadStoredHeader(db, tableName)
+        if err != nil {
+            return nil, err
         }
+        if ok {
+            prev = stored
+        }
+    }
+    if err := FinishHeader(dbc, prev); err != nil {
+        return nil, err
+    }
+    return dbc, nil
+}
 
-        for j := 0; j < repeat; j++ {
-            if f.Type == "Loc" {
-                count += 17
-            } else {
-                count++
+// resultColumns resolves the result column of every schema column, -1 if missing
+func resultColumns(schema *Schema, cols []string) []int {
+    colIndex := make([]int, len(schema.Columns))
+    for c, col := range schema.Columns {
+        colIndex[c] = -1
+        for i, name := range cols {
+            if name == col.Name {
+                colIndex[c] = i
+                break
             }
         }
     }
-    return uint32(count)
+    return colIndex
+}
+
+// columnReader converts the columns of scanned table rows to raw column bits
+type columnReader struct {
+    schema    *Schema
+    colIndex  []int       // result column of each schema column, -1 if missing
+    bitsIndex []int       // result column of a float's raw bits, -1 if missing
+    rounded   map[int]int // per column, values that were rounded to a float
 }
 
-func toInt32(raw []interface{}, cols []string, name string) int32 {
-    for i, col := range cols {
-        if col == name && raw[i] != nil {
-            if v, ok := raw[i].(int64); ok {
-                return int32(v)
+// newColumnReader resolves the schema columns in a result's column names
+func newColumnReader(schema *Schema, cols []string) *columnReader {
+    r := &columnReader{
+        schema:    schema,
+        colIndex:  resultColumns(schema, cols),
+        bitsIndex: make([]int, len(schema.Columns)),
+        rounded:   map[int]int{},
+    }
+    for c, col := range schema.Columns {
+        r.bitsIndex[c] = -1
+        if col.Type != "float" {
+            continue
+        }
+        for i, name := range cols {
+            if name == floatBitsColumn(col.Name) {
+                r.bitsIndex[c] = i
+                break
             }
         }
     }
-    return 0
+    return r
 }
 
-func toUint32(raw []interface{}, cols []string, name string) uint32 {
-    for i, col := range cols {
-        if col == name && raw[i] != nil {
-            switch v := raw[i].(type) {
-            case int64:
-                return uint32(v)
-            case uint64:
-                return uint32(v)
-            }
+// value returns the bits of column c in a scanned row, or false if the table lacks
+// the column. Strings are added to the string block through addString.
+func (r *columnReader) value(raw []interface{}, c int, addString func(string) uint32) (uint64, bool) {
+    if r.colIndex[c] < 0 {
+        return 0, false
+    }
+    col := r.schema.Columns[c]
+    v := refValue(r.schema.Meta, col, raw[r.colIndex[c]])
+    if col.Type != "float" {
+        return sqlValueBits(col, v, addString), true
+    }
+
+    var stored interface{}
+    if r.bitsIndex[c] >= 0 {
+        stored = raw[r.bitsIndex[c]]
+    }
+    bits, exact := floatBits(v, stored)
+    if !exact {
+        r.rounded[c]++
+    }
+    return bits, true
+}
+
+// report logs the columns holding values that are not floats and were rounded
+func (r *columnReader) report(tableName string) {
+    for c, col := range r.schema.Columns {
+        if n := r.rounded[c]; n > 0 {
+            log.Printf("Warning: %d values of %s.%s are not exact floats and were rounded", n, tableName, col.Name)
         }
     }
+}
+
+// refValue maps NULL in a foreign key column back to the value meaning no reference
+func refValue(meta *MetaFile, col Column, v interface{}) interface{} {
+    if ref := meta.Fields[col.Field].Ref; v == nil && ref != nil && len(ref.None) > 0 {
+        return ref.None[0]
+    }
+    return v
+}
+
+// sqlValueBits converts a scanned SQL value to the raw bits stored for a column.
+// Strings are added to the string block through addString.
+func sqlValueBits(col Column, v interface{}, addString func(string) uint32) uint64 {
+    switch col.Type {
+    case "int8", "int16", "int32", "int64":
+        return uint64(toInt64(v))
+    case "uint8", "uint16", "uint32", "uint64":
+        return toUint64(v)
+    case "float":
+        f, _ := toFloat32(v)
+        return uint64(math.Float32bits(f))
+    case "string":
+        return uint64(addString(toString(v)))
+    }
     return 0
 }
 
-func toUint8(raw []interface{}, cols []string, name string) uint8 {
-    for i, col := range cols {
-        if col == name && raw[i] != nil {
-            switch v := raw[i].(type) {
-            case int64:
-                return uint8(v)
-            case uint64:
-                return uint8(v)
-            case []byte:
-                if n, err := strconv.ParseUint(string(v), 10, 8); err == nil {
-                    return uint8(n)
-                }
-            case string:
-                if n, err := strconv.ParseUint(v, 10, 8); err == nil {
-                    return uint8(n)
-                }
-            }
+// floatBits converts a float column to its bits. The raw bits stored with
+// options.float_storage "bits" win, unless the readable value was edited since.
+func floatBits(v, stored interface{}) (uint64, bool) {
+    f, exact := toFloat32(v)
+    if stored != nil {
+        bits := uint32(toUint64(stored))
+        s := math.Float32frombits(bits)
+        // NaN and infinities are NULL, -0 reads back as 0
+        if v == nil || s == f || s != s && f != f {
+            return uint64(bits), true
         }
     }
-    return 0
+    return uint64(math.Float32bits(f)), exact
 }
 
-func toFloat32(raw []interface{}, cols []string, name string) float32 {
-    for i, col := range cols {
-        if col == name && raw[i] != nil {
-            switch v := raw[i].(type) {
-            case float64:
-                return float32(v)
-            case float32:
-                return v
-            case []byte:
-                if f, err := strconv.ParseFloat(string(v), 64); err == nil {
-                    return float32(f)
-                }
-            case string:
-                if f, err := strconv.ParseFloat(v, 64); err == nil {
-                    return float32(f)
-                }
-            }
+func buildOrderBy(d Dialect, sort []SortField) string {
+    if len(sort) == 0 {
+        return ""
+    }
+    parts := make([]string, len(sort))
+    for i, sf := range sort {
+        dir := strings.ToUpper(sf.Direction)
+        if dir != "ASC" && dir != "DESC" {
+            dir = "ASC"
+        }
+        parts[i] = d.Quote(sf.Name) + " " + dir
+    }
+    return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+func getStringOffset(s string, block *[]byte, offsets map[string]uint32) uint32 {
+    if off, ok := offsets[s]; ok {
+        return off
+    }
+    off := uint32(len(*block))
+    *block = append(*block, []byte(s)...)
+    *block = append(*block, 0)
+    offsets[s] = off
+    return off
+}
+
+// toInt64 converts a scanned SQL value to a signed integer
+func toInt64(v interface{}) int64 {
+    switch v := v.(type) {
+    case int64:
+        return v
+    case uint64:
+        return int64(v)
+    case []byte:
+        if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+            return n
+        }
+    case string:
+        if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+            return n
         }
     }
     return 0
 }
 
-func toString(raw []interface{}, cols []string, name string) string {
-    for i, col := range cols {
-        if col == name && raw[i] != nil {
-            switch v := raw[i].(type) {
-            case string:
-                return v
-            case []byte:
-                return string(v)
-            }
+// toUint64 converts a scanned SQL value to an unsigned integer
+func toUint64(v interface{}) uint64 {
+    switch v := v.(type) {
+    case int64:
+        return uint64(v)
+    case uint64:
+        return v
+    case []byte:
+        return toUint64(string(v))
+    case string:
+        if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+            return n
+        }
+        if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+            return uint64(n)
         }
     }
-    return ""
+    return 0
+}
+
+// toFloat32 converts a scanned SQL value to a float. exact reports whether the value
+// round-trips, i.e. storing the float again gives the same value; otherwise it was
+// rounded, for example after being edited to a number a float cannot hold.
+func toFloat32(v interface{}) (f float32, exact bool) {
+    switch v := v.(type) {
+    case nil:
+        return 0, true
+    case float64:
+        f = float32(v)
+        return f, float64(f) == v || v != v
+    case float32:
+        return v, true
+    case int64:
+        f = float32(v)
+        return f, float64(f) == float64(v)
+    case []byte:
+        return parseFloat32(string(v))
+    case string:
+        return parseFloat32(v)
+    }
+    return 0, false
 }
 
-// getTableChecksum returns the CHECKSUM TABLE value
-func getTableChecksum(db *sql.DB, tableName string) (uint64, error) {
-    var tbl string
-    var checksum sql.NullInt64
-    err := db.QueryRow("CHECKSUM TABLE `" + tableName + "`").Scan(&tbl, &checksum)
+// parseFloat32 converts a number printed by the database. A DECIMAL holding a float
+// is rounded to its scale, so it round-trips if the float prints at that scale as
+// the same digits.
+func parseFloat32(s string) (float32, bool) {
+    d, err := strconv.ParseFloat(s, 64)
     if err != nil {
-        return 0, err
+        return 0, false
     }
-    if !checksum.Valid {
-        return 0, nil
+    f := float32(d)
+    if float64(f) == d || d != d {
+        return f, true
+    }
+    if i := strings.IndexByte(s, '.'); i >= 0 && !strings.ContainsAny(s, "eE") {
+        return f, strconv.FormatFloat(float64(f), 'f', len(s)-i-1, 64) == s
+    }
+    return f, false
+}
+
+// toString converts a scanned SQL value to text
+func toString(v interface{}) string {
+    switch v := v.(type) {
+    case string:
+        return v
+    case []byte:
+        return string(v)
     }
-    return uint64(checksum.Int64), nil
+    return ""
+}
+
+// getTableChecksum returns a checksum of the table's data: CHECKSUM TABLE on MySQL,
+// a hash of all rows on backends without it
+func getTableChecksum(db *DB, tableName string) (uint64, error) {
+    return db.Dialect.Checksum(db.DB, tableName)
 }
 
 // getStoredChecksum retrieves the stored checksum from dbc_checksum
-func getStoredChecksum(db *sql.DB, tableName string) (uint64, error) {
+func getStoredChecksum(db *DB, tableName string) (uint64, error) {
     var cs sql.NullInt64
     err := db.QueryRow("SELECT checksum FROM dbc_checksum WHERE table_name = ?", tableName).Scan(&cs)
     if err == sql.ErrNoRows {
@@ -349,7 +517,7 @@ func getStoredChecksum(db *sql.DB, tableName string) (uint64, error) {
 }
 
 // updateChecksum updates the stored checksum for a table
-func updateChecksum(db *sql.DB, tableName string, checksum uint64) error {
+func updateChecksum(db *DB, tableName string, checksum uint64) error {
     _, err := db.Exec("UPDATE dbc_checksum SET checksum = ? WHERE table_name = ?", checksum, tableName)
     return err
 }
\ No newline at end of file
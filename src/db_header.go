@@ -0,0 +1,98 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "database/sql"
+    "encoding/binary"
+)
+
+// ensureHeaderTable ensures the dbc_header table exists.
+// It keeps the WDB2 header fields and index arrays that have no column in the data table.
+func ensureHeaderTable(db execer, d Dialect) error {
+    u32, _ := d.ColumnType("uint32")
+    blob := d.BlobType()
+    query := `
+    CREATE TABLE IF NOT EXISTS dbc_header (
+        table_name VARCHAR(255) NOT NULL PRIMARY KEY,
+        magic CHAR(4) NOT NULL,
+        table_hash ` + u32 + ` NOT NULL DEFAULT 0,
+        build ` + u32 + ` NOT NULL DEFAULT 0,
+        timestamp ` + u32 + ` NOT NULL DEFAULT 0,
+        min_id ` + u32 + ` NOT NULL DEFAULT 0,
+        max_id ` + u32 + ` NOT NULL DEFAULT 0,
+        locale ` + u32 + ` NOT NULL DEFAULT 0,
+        record_count ` + u32 + ` NOT NULL DEFAULT 0,
+        index_table ` + blob + `,
+        string_lengths ` + blob + `,
+        copy_table ` + blob + `
+    )`
+    _, err := db.Exec(query)
+    return err
+}
+
+// storeHeader saves the header of an imported file into dbc_header
+func storeHeader(db execer, d Dialect, tableName string, dbc *DBCFile) error {
+    if err := ensureHeaderTable(db, d); err != nil {
+        return err
+    }
+
+    indexData := make([]byte, len(dbc.IndexTable)*4)
+    for i, v := range dbc.IndexTable {
+        binary.LittleEndian.PutUint32(indexData[i*4:], v)
+    }
+    lengthData := make([]byte, len(dbc.StringLengths)*2)
+    for i, v := range dbc.StringLengths {
+        binary.LittleEndian.PutUint16(lengthData[i*2:], v)
+    }
+
+    h := dbc.Header
+    _, err := db.Exec(`INSERT INTO dbc_header
+        (table_name, magic, table_hash, build, timestamp, min_id, max_id, locale, record_count, index_table, string_lengths, copy_table)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `+d.Upsert([]string{"table_name"}, []string{"magic", "table_hash", "build", "timestamp", "min_id", "max_id", "locale",
+            "record_count", "index_table", "string_lengths", "copy_table"}),
+        tableName, h.Format(), h.TableHash, h.Build, h.Timestamp, h.MinID, h.MaxID, h.Locale, h.RecordCount,
+        indexData, lengthData, dbc.CopyTable)
+    return err
+}
+
+// loadStoredHeader reads a header saved by storeHeader. The returned DBCFile
+// carries only the header and index arrays; ok is false if nothing was stored.
+func loadStoredHeader(db *DB, tableName string) (*DBCFile, bool, error) {
+    if err := ensureHeaderTable(db, db.Dialect); err != nil {
+        return nil, false, err
+    }
+
+    var magic string
+    var indexData, lengthData, copyTable []byte
+    dbc := &DBCFile{}
+    h := &dbc.Header
+    err := db.QueryRow(`SELECT magic, table_hash, build, timestamp, min_id, max_id, locale, record_count,
+        index_table, string_lengths, copy_table FROM dbc_header WHERE table_name = ?`, tableName).
+        Scan(&magic, &h.TableHash, &h.Build, &h.Timestamp, &h.MinID, &h.MaxID, &h.Locale, &h.RecordCount,
+            &indexData, &lengthData, &copyTable)
+    if err == sql.ErrNoRows {
+        return nil, false, nil
+    }
+    if err != nil {
+        return nil, false, err
+    }
+
+    copy(h.Magic[:], magic)
+    dbc.IndexTable = make([]uint32, len(indexData)/4)
+    for i := range dbc.IndexTable {
+        dbc.IndexTable[i] = binary.LittleEndian.Uint32(indexData[i*4:])
+    }
+    dbc.StringLengths = make([]uint16, len(lengthData)/2)
+    for i := range dbc.StringLengths {
+        dbc.StringLengths[i] = binary.LittleEndian.Uint16(lengthData[i*2:])
+    }
+    dbc.CopyTable = copyTable
+    h.CopyTableSize = uint32(len(copyTable))
+
+    return dbc, true, nil
+}
@@ -9,10 +9,10 @@ import (
     "database/sql"
     "fmt"
     "log"
-    "os"
+    "math"
     "path/filepath"
+    "strconv"
     "strings"
-    "sort"
 )
 
 var locLangs = []string{
@@ -22,7 +22,7 @@ var locLangs = []string{
 }
 
 // ImportDBCs scans the meta directory and imports all DBCs
-func ImportDBCs(db *sql.DB, force bool, cfg *Config) error {
+func ImportDBCs(db *DB, force bool, cfg *Config) error {
     metas, err := filepath.Glob(filepath.Join(cfg.Paths.Meta, "*.meta.json"))
     if err != nil {
         return fmt.Errorf("failed to scan meta directory: %w", err)
@@ -34,12 +34,15 @@ func ImportDBCs(db *sql.DB, force bool, cfg *Config) error {
         }
     }
 
+    if cfg.Options.ForeignKeys {
+        return AddForeignKeys(db, cfg)
+    }
     return nil
 }
 
 // ImportDBC imports a single DBC into SQL based on its meta
-func ImportDBC(db *sql.DB, force bool, cfg *Config, metaPath string) error {
-    if err := ensureChecksumTable(db); err != nil {
+func ImportDBC(db *DB, force bool, cfg *Config, metaPath string) error {
+    if err := ensureChecksumTable(db, db.Dialect); err != nil {
         return fmt.Errorf("failed to ensure dbc_checksum table: %w", err)
     }
     
@@ -48,18 +51,9 @@ func ImportDBC(db *sql.DB, force bool, cfg *Config, metaPath string) error {
         return fmt.Errorf("failed to load meta %s: %w", metaPath, err)
     }
 
-    tableName := strings.TrimSuffix(filepath.Base(meta.File), ".dbc")
-    if meta.TableName != "" {
-        tableName = meta.TableName
-    }
+    tableName := resolveTableName(&meta, cfg)
     
-    if cfg.Options.UseLowercaseTables {
-        tableName = strings.ToLower(tableName)
-    }
-    
-    dbcPath := filepath.Join(cfg.Paths.Base, meta.File)
-
-    if _, err := os.Stat(dbcPath); os.IsNotExist(err) {
+    if !dbcExists(cfg, meta.File) {
         log.Printf("Skipping %s: DBC file does not exist", tableName)
         return nil
     }
@@ -73,48 +67,84 @@ func ImportDBC(db *sql.DB, force bool, cfg *Config, metaPath string) error {
         return nil
     }
 
-    log.Printf("Importing %s into table %s...", dbcPath, tableName)
+    log.Printf("Importing %s into table %s...", meta.File, tableName)
 
-    dbc, err := LoadDBC(dbcPath, meta)
+    dbc, err := LoadDBCFile(cfg, meta)
     if err != nil {
-        return fmt.Errorf("failed to load DBC %s: %w", dbcPath, err)
+        return fmt.Errorf("failed to load DBC %s: %w", meta.File, err)
     }
 
-    checkUniqueKeys(dbc.Records, &meta, tableName)
+    checkUniqueKeys(&dbc, tableName)
 
-    if err := createTable(db, tableName, &meta); err != nil {
+    var fks []foreignKey
+    if cfg.Options.ForeignKeys {
+        fks = foreignKeys(cfg, tableName, dbc.Table.Schema)
+    }
+
+    floats := cfg.Options.FloatStorage
+    if err := createTable(db, db.Dialect, tableName, dbc.Table.Schema, floats, fks); err != nil {
         return fmt.Errorf("failed to create table %s: %w", tableName, err)
     }
 
-    if err := insertRecords(db, tableName, &dbc, &meta); err != nil {
+    if err := insertRecords(db, tableName, &dbc, floats, fks); err != nil {
         return fmt.Errorf("failed to insert records for %s: %w", tableName, err)
     }
 
-    log.Printf("Imported %s into table %s", dbcPath, tableName)
+    if dbc.Header.Format() == FormatWDB2 {
+        if err := storeHeader(db, db.Dialect, tableName, &dbc); err != nil {
+            return fmt.Errorf("failed to store header for %s: %w", tableName, err)
+        }
+    }
+
+    log.Printf("Imported %s into table %s", meta.File, tableName)
     return nil
 }
 
+// resolveTableName returns the SQL table name for a meta
+func resolveTableName(meta *MetaFile, cfg *Config) string {
+    base := filepath.Base(meta.File)
+    tableName := strings.TrimSuffix(base, filepath.Ext(base))
+    if meta.TableName != "" {
+        tableName = meta.TableName
+    }
+
+    if cfg.Options.UseLowercaseTables {
+        tableName = strings.ToLower(strings.TrimSpace(tableName))
+    }
+    return tableName
+}
+
 // checkUniqueKeys scans records for duplicates based on meta.UniqueKeys
-func checkUniqueKeys(records []Record, meta *MetaFile, tableName string) {
-    for i, uk := range meta.UniqueKeys {
+func checkUniqueKeys(dbc *DBCFile, tableName string) {
+    table := dbc.Table
+    schema := table.Schema
+    for i, uk := range schema.Meta.UniqueKeys {
         if len(uk) == 0 {
             continue
         }
 
+        cols := make([]int, len(uk))
+        for j, name := range uk {
+            cols[j] = -1
+            if c, ok := schema.Lookup(name); ok {
+                cols[j] = c
+            }
+        }
+
         seen := map[string][]int{} // map[keyString] -> list of record indices
 
-        for idx, rec := range records {
+        for row := 0; row < table.Len(); row++ {
             var keyParts []string
-            for _, col := range uk {
-                val, ok := rec[col]
-                if !ok {
-                    val = "<MISSING>"
+            for _, c := range cols {
+                val := interface{}("<MISSING>")
+                if c >= 0 {
+                    val = table.Value(row, c)
                 }
                 keyParts = append(keyParts, fmt.Sprintf("%v", val))
             }
 
             keyStr := strings.Join(keyParts, ":")
-            seen[keyStr] = append(seen[keyStr], idx)
+            seen[keyStr] = append(seen[keyStr], row)
         }
 
         for _, indices := range seen {
@@ -123,14 +153,8 @@ func checkUniqueKeys(records []Record, meta *MetaFile, tableName string) {
                     tableName, i, uk)
                 for _, idx := range indices {
                     fmt.Printf("  Record %d: {\n", idx)
-                    rec := records[idx]
-                    keys := make([]string, 0, len(rec))
-                    for k := range rec {
-                        keys = append(keys, k)
-                    }
-                    sort.Strings(keys)
-                    for _, k := range keys {
-                        fmt.Printf("    %s: %v\n", k, rec[k])
+                    for c, col := range schema.Columns {
+                        fmt.Printf("    %s: %v\n", col.Name, table.Value(idx, c))
                     }
                     fmt.Println("  }")
                 }
@@ -140,19 +164,18 @@ func checkUniqueKeys(records []Record, meta *MetaFile, tableName string) {
 }
 
 // tableExists checks if a table already exists
-func tableExists(db *sql.DB, force bool, table string) bool {
-    var exists string
-    err := db.QueryRow("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", table).Scan(&exists)
-    if err == sql.ErrNoRows {
-        return false
-    }
+func tableExists(db *DB, force bool, table string) bool {
+    exists, err := db.Dialect.TableExists(db.DB, table)
     if err != nil {
         log.Printf("Warning: could not check table %s: %v", table, err)
         return false
     }
+    if !exists {
+        return false
+    }
     if force {
         log.Printf("Force flag enabled: dropping existing table %s", table)
-        _, dropErr := db.Exec("DROP TABLE IF EXISTS `" + table + "`")
+        _, dropErr := db.Exec(db.Dialect.DropTable(table))
         if dropErr != nil {
             log.Printf("Error dropping table %s: %v", table, dropErr)
         }
@@ -161,59 +184,36 @@ func tableExists(db *sql.DB, force bool, table string) bool {
     return true
 }
 
-// createTable constructs table based on meta, Loc fields, and unique keys
-func createTable(db *sql.DB, tableName string, meta *MetaFile) error {
+// createTable constructs table based on meta, Loc fields, unique keys, indexes on
+// referencing columns and the foreign keys the dialect declares inline. Floats are
+// stored as options.float_storage says.
+func createTable(db execer, d Dialect, tableName string, schema *Schema, floats string, fks []foreignKey) error {
+    meta := schema.Meta
     var columns []string
 
-    validFields := make(map[string]struct{})
-    for _, field := range meta.Fields {
-        repeat := int(field.Count)
-        if repeat == 0 {
-            repeat = 1
+    for _, col := range schema.Columns {
+        typ := col.Type
+        if typ == "float" && floats != "decimal" {
+            typ = "double"
         }
-
-        for j := 0; j < repeat; j++ {
-            colName := field.Name
-            if field.Count > 1 {
-                colName = fmt.Sprintf("%s_%d", field.Name, j+1)
-            }
-
-            switch field.Type {
-            case "int32":
-                columns = append(columns, fmt.Sprintf("`%s` INT", colName))
-            case "uint32":
-                columns = append(columns, fmt.Sprintf("`%s` INT UNSIGNED", colName))
-            case "uint8":
-                columns = append(columns, fmt.Sprintf("`%s` TINYINT UNSIGNED", colName))
-            case "float":
-                columns = append(columns, fmt.Sprintf("`%s` DECIMAL(38,16)", colName))
-            case "string":
-                columns = append(columns, fmt.Sprintf("`%s` TEXT", colName))
-            case "Loc":
-                for i, lang := range locLangs {
-                    locCol := fmt.Sprintf("%s_%s", colName, lang)
-                    if i == len(locLangs)-1 {
-                        columns = append(columns, fmt.Sprintf("`%s` INT UNSIGNED", locCol))
-                    } else {
-                        columns = append(columns, fmt.Sprintf("`%s` TEXT", locCol))
-                    }
-                }
-            default:
-                return fmt.Errorf("unknown field type: %s", field.Type)
-            }
-
-            // track valid field name
-            validFields[colName] = struct{}{}
+        sqlType, err := d.ColumnType(typ)
+        if err != nil {
+            return err
+        }
+        columns = append(columns, d.Quote(col.Name)+" "+sqlType)
+        if col.Type == "float" && floats == "bits" {
+            bitsType, _ := d.ColumnType("uint32")
+            columns = append(columns, d.Quote(floatBitsColumn(col.Name))+" "+bitsType)
         }
     }
 
     // Default PK handling
-    pkCols := []string{"`auto_id`"} // default if nothing set
+    pkCols := []string{d.Quote("auto_id")} // default if nothing set
     if len(meta.PrimaryKeys) > 0 {
         var validPKs []string
         for _, pkc := range meta.PrimaryKeys {
-            if _, ok := validFields[pkc]; ok {
-                validPKs = append(validPKs, fmt.Sprintf("`%s`", pkc))
+            if _, ok := schema.Lookup(pkc); ok {
+                validPKs = append(validPKs, d.Quote(pkc))
             }
         }
 
@@ -222,15 +222,15 @@ func createTable(db *sql.DB, tableName string, meta *MetaFile) error {
         } else {
             // fallback: add surrogate key
             log.Printf("No valid primary keys found for %s; using auto-increment surrogate key `auto_id`", tableName)
-            columns = append([]string{"`auto_id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT"}, columns...)
-            pkCols = []string{"`auto_id`"}
+            columns = append([]string{d.SurrogateKey()}, columns...)
+            pkCols = []string{d.Quote("auto_id")}
         }
     }
 
     // Build CREATE TABLE
     query := fmt.Sprintf(
-        "CREATE TABLE IF NOT EXISTS `%s` (%s, PRIMARY KEY(%s)",
-        tableName, strings.Join(columns, ", "), strings.Join(pkCols, ", "),
+        "CREATE TABLE IF NOT EXISTS %s (%s, PRIMARY KEY(%s)",
+        d.Quote(tableName), strings.Join(columns, ", "), strings.Join(pkCols, ", "),
     )
 
     // Add unique keys dynamically
@@ -238,23 +238,47 @@ func createTable(db *sql.DB, tableName string, meta *MetaFile) error {
         if len(uk) == 0 {
             continue
         }
-        cols := make([]string, len(uk))
-        for j, c := range uk {
-            cols[j] = fmt.Sprintf("`%s`", c)
+        query += ", " + d.UniqueKey(tableName, i, uk)
+    }
+
+    // Index referencing columns, unless they lead the primary key
+    var stmts []string
+    for _, col := range schema.Columns {
+        ref := meta.Fields[col.Field].Ref
+        if ref == nil || col.Type == "string" || col.Type == "float" || d.Quote(col.Name) == pkCols[0] {
+            continue
+        }
+        clause, stmt := d.Index(tableName, []string{col.Name})
+        if clause != "" {
+            query += ", " + clause
+        }
+        if stmt != "" {
+            stmts = append(stmts, stmt)
+        }
+    }
+
+    for _, fk := range fks {
+        if clause, _ := d.ForeignKey(tableName, schema.Columns[fk.Column].Name, fk.RefTable, fk.RefColumn); clause != "" {
+            query += ", " + clause
         }
-        query += fmt.Sprintf(", UNIQUE KEY `uk_%d` (%s)", i, strings.Join(cols, ", "))
     }
 
     query += ")"
 
-    _, err := db.Exec(query)
-    return err
+    if _, err := db.Exec(query); err != nil {
+        return err
+    }
+    for _, stmt := range stmts {
+        if _, err := db.Exec(stmt); err != nil {
+            return err
+        }
+    }
+    return nil
 }
 
 // insertRecords inserts all DBC records into SQL
-func insertRecords(db *sql.DB, tableName string, dbc *DBCFile, meta *MetaFile) error {
-    total := len(dbc.Records)
-    if total == 0 {
+func insertRecords(db *DB, tableName string, dbc *DBCFile, floats string, fks []foreignKey) error {
+    if dbc.Table.Len() == 0 {
         return nil
     }
 
@@ -264,34 +288,45 @@ func insertRecords(db *sql.DB, tableName string, dbc *DBCFile, meta *MetaFile) e
     }
     defer tx.Rollback() // safe rollback if Commit not reached
 
-    columnsBase := make([]string, 0, len(meta.Fields)*len(locLangs))
-    for _, field := range meta.Fields {
-        repeat := int(field.Count)
-        if repeat == 0 {
-            repeat = 1
-        }
+    if err := insertRows(tx, db.Dialect, tableName, dbc, floats, fks); err != nil {
+        return err
+    }
 
-        for j := 0; j < repeat; j++ {
-            colName := field.Name
-            if field.Count > 1 {
-                colName = fmt.Sprintf("%s_%d", field.Name, j+1)
-            }
-            switch field.Type {
-            case "int32", "uint32", "uint8", "float", "string":
-                columnsBase = append(columnsBase, fmt.Sprintf("`%s`", colName))
-            case "Loc":
-                for _, lang := range locLangs {
-                    columnsBase = append(columnsBase, fmt.Sprintf("`%s_%s`", colName, lang))
-                }
-            }
+    if err := tx.Commit(); err != nil {
+        return err
+    }
+
+    log.Println("100% complete!")
+
+    return nil
+}
+
+// insertRows writes all records as batched upserts. Values of foreign key columns
+// that mean no reference are written as NULL.
+func insertRows(db execer, d Dialect, tableName string, dbc *DBCFile, floats string, fks []foreignKey) error {
+    table := dbc.Table
+    nulls := refNulls(fks)
+    total := table.Len()
+    columns := table.Schema.Columns
+    var columnsBase []string
+    for _, col := range columns {
+        columnsBase = append(columnsBase, d.Quote(col.Name))
+        if col.Type == "float" && floats == "bits" {
+            columnsBase = append(columnsBase, d.Quote(floatBitsColumn(col.Name)))
         }
     }
+    rowPlaceholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columnsBase)), ", ") + ")"
+
+    keyCols := conflictColumns(table.Schema)
+    keys := make([]string, len(keyCols))
+    for i, c := range keyCols {
+        keys[i] = d.Quote(columns[c].Name)
+    }
+    upsert := d.Upsert(keys, columnsBase)
 
     // calculate batch size
     colsPerRow := len(columnsBase)
-    // stay below 65535 max batch size
-    maxPlaceholders := 60000
-    batchSize := maxPlaceholders / colsPerRow
+    batchSize := d.MaxParams() / colsPerRow
     if batchSize > 2000 {
         batchSize = 2000
     }
@@ -299,65 +334,25 @@ func insertRecords(db *sql.DB, tableName string, dbc *DBCFile, meta *MetaFile) e
     // progress tracking
     nextPercent := 15
 
-    // process in batches
-    for start := 0; start < total; start += batchSize {
-        end := start + batchSize
-        if end > total {
-            end = total
-        }
-        records := dbc.Records[start:end]
-
-        var allPlaceholders []string
-        var allValues []interface{}
+    start := 0
+    allPlaceholders := make([]string, 0, batchSize)
+    allValues := make([]interface{}, 0, batchSize*colsPerRow)
+    batchKeys := map[string]bool{}
+    inexact := map[string]int{} // floats per column the storage cannot hold
 
-        for _, rec := range records {
-            var rowPlaceholders []string
-            for _, field := range meta.Fields {
-                repeat := int(field.Count)
-                if repeat == 0 {
-                    repeat = 1
-                }
-                for j := 0; j < repeat; j++ {
-                    name := field.Name
-                    if field.Count > 1 {
-                        name = fmt.Sprintf("%s_%d", field.Name, j+1)
-                    }
-                    switch field.Type {
-                    case "int32", "uint32", "uint8", "float":
-                        rowPlaceholders = append(rowPlaceholders, "?")
-                        allValues = append(allValues, rec[name])
-                    case "string":
-                        rowPlaceholders = append(rowPlaceholders, "?")
-                        offset := rec[name].(uint32)
-                        allValues = append(allValues, readString(dbc.StringBlock, offset))
-                    case "Loc":
-                        locArr := rec[name].([]uint32)
-                        numTexts := len(locArr) - 1
-                        for i := range locLangs {
-                            if i < numTexts {
-                                allValues = append(allValues, readString(dbc.StringBlock, locArr[i]))
-                            } else if i == numTexts {
-                                allValues = append(allValues, locArr[numTexts]) // flags
-                            } else {
-                                allValues = append(allValues, nil) // extra unused
-                            }
-                            rowPlaceholders = append(rowPlaceholders, "?")
-                        }
-                    }
-                }
-            }
-            allPlaceholders = append(allPlaceholders, "("+strings.Join(rowPlaceholders, ", ")+")")
+    flush := func(end int) error {
+        if len(allPlaceholders) == 0 {
+            return nil
         }
-
         query := fmt.Sprintf(
-            "INSERT INTO `%s` (%s) VALUES %s ON DUPLICATE KEY UPDATE %s",
-            tableName,
+            "INSERT INTO %s (%s) VALUES %s %s",
+            d.Quote(tableName),
             strings.Join(columnsBase, ", "),
             strings.Join(allPlaceholders, ", "),
-            generateUpdateAssignments(columnsBase),
+            upsert,
         )
 
-        if _, err := tx.Exec(query, allValues...); err != nil {
+        if _, err := db.Exec(query, allValues...); err != nil {
             return fmt.Errorf("batch insert failed (%d–%d): %v", start, end, err)
         }
 
@@ -367,39 +362,137 @@ func insertRecords(db *sql.DB, tableName string, dbc *DBCFile, meta *MetaFile) e
             log.Printf("%d%% complete.. (%d/%d rows)\n", done, end, total)
             nextPercent += 15
         }
+
+        start = end
+        allPlaceholders = allPlaceholders[:0]
+        allValues = allValues[:0]
+        batchKeys = map[string]bool{}
+        return nil
     }
 
-    if err := tx.Commit(); err != nil {
-        return err
+    for row := 0; row < total; row++ {
+        // a statement may update each key only once on PostgreSQL, so a repeated key
+        // starts a new batch and updates the earlier row as MySQL would
+        if len(keyCols) > 0 {
+            parts := make([]string, len(keyCols))
+            for i, c := range keyCols {
+                parts[i] = formatValue(dbc, row, c)
+            }
+            key := strings.Join(parts, ":")
+            if batchKeys[key] {
+                if err := flush(row); err != nil {
+                    return err
+                }
+            }
+            batchKeys[key] = true
+        }
+
+        for c, col := range columns {
+            if none, ok := nulls[c]; ok && containsInt(none, table.Int(row, c)) {
+                allValues = append(allValues, nil)
+            } else if col.Type == "string" {
+                allValues = append(allValues, dbc.Text(row, c))
+            } else if col.Type == "float" {
+                bits := uint32(table.Raw(row, c))
+                f := float64(math.Float32frombits(bits))
+                if !floatStorable(floats, f) {
+                    inexact[col.Name]++
+                }
+                if math.IsNaN(f) || math.IsInf(f, 0) {
+                    allValues = append(allValues, nil)
+                } else {
+                    allValues = append(allValues, f)
+                }
+                if floats == "bits" {
+                    allValues = append(allValues, bits)
+                }
+            } else if col.Type == "uint64" {
+                allValues = append(allValues, d.Uint64(table.Uint(row, c)))
+            } else {
+                allValues = append(allValues, table.Value(row, c))
+            }
+        }
+        allPlaceholders = append(allPlaceholders, rowPlaceholders)
+
+        if len(allPlaceholders) == batchSize {
+            if err := flush(row + 1); err != nil {
+                return err
+            }
+        }
     }
 
-    log.Println("100% complete!")
+    if err := flush(total); err != nil {
+        return err
+    }
 
+    for _, col := range columns {
+        if n := inexact[col.Name]; n > 0 {
+            log.Printf("Warning: %d values of %s.%s cannot be stored exactly as %s; set options.float_storage to \"bits\"",
+                n, tableName, col.Name, floats)
+        }
+    }
     return nil
 }
 
-// generateUpdateAssignments generates the ON DUPLICATE KEY UPDATE clause
-func generateUpdateAssignments(columns []string) string {
-    assignments := make([]string, len(columns))
-    for i, col := range columns {
-        assignments[i] = fmt.Sprintf("%s=VALUES(%s)", col, col)
+// floatBitsColumn names the column holding the raw bits of a float with
+// options.float_storage "bits"
+func floatBitsColumn(name string) string {
+    return name + "_bits"
+}
+
+// floatStorable reports whether a float survives options.float_storage unchanged.
+// NaN and infinities are stored as NULL, a zero may lose its sign, and DECIMAL(38,16)
+// also rounds to 16 decimals.
+func floatStorable(floats string, f float64) bool {
+    switch {
+    case floats == "bits":
+        return true
+    case math.IsNaN(f) || math.IsInf(f, 0) || f == 0 && math.Signbit(f):
+        return false
+    case floats == "decimal":
+        d, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'f', 16, 64), 64)
+        return math.Abs(f) < 1e22 && float32(d) == float32(f)
+    }
+    return true
+}
+
+// conflictColumns returns the columns an upsert matches existing rows by: the primary
+// key, or the first unique key of tables keyed by the auto_id surrogate
+func conflictColumns(schema *Schema) []int {
+    if cols := keyColumns(schema); len(cols) > 0 {
+        return cols
     }
-    return strings.Join(assignments, ", ")
+    for _, uk := range schema.Meta.UniqueKeys {
+        var cols []int
+        for _, name := range uk {
+            c, ok := schema.Lookup(name)
+            if !ok {
+                cols = nil
+                break
+            }
+            cols = append(cols, c)
+        }
+        if len(cols) > 0 {
+            return cols
+        }
+    }
+    return nil
 }
 
 // ensureChecksumTable ensures the dbc_checksum table exists
-func ensureChecksumTable(db *sql.DB) error {
+func ensureChecksumTable(db execer, d Dialect) error {
+    checksumType, _ := d.ColumnType("uint64")
     query := `
     CREATE TABLE IF NOT EXISTS dbc_checksum (
         table_name VARCHAR(255) NOT NULL PRIMARY KEY,
-        checksum BIGINT UNSIGNED NOT NULL DEFAULT 0
+        checksum ` + checksumType + ` NOT NULL DEFAULT 0
     )`
     _, err := db.Exec(query)
     return err
 }
 
 // ensureChecksumEntry makes sure a row for the table exists in dbc_checksum
-func ensureChecksumEntry(db *sql.DB, tableName string) error {
+func ensureChecksumEntry(db *DB, tableName string) error {
     var exists int
     err := db.QueryRow("SELECT 1 FROM dbc_checksum WHERE table_name = ?", tableName).Scan(&exists)
     if err == sql.ErrNoRows {
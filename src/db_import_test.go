@@ -0,0 +1,108 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "database/sql"
+    "database/sql/driver"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// recordingExecer keeps the arguments of every statement instead of running it
+type recordingExecer struct {
+    args [][]interface{}
+}
+
+func (e *recordingExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+    e.args = append(e.args, append([]interface{}(nil), args...))
+    return nil, nil
+}
+
+func TestInsertRowsUint64(t *testing.T) {
+    meta := &MetaFile{File: "Test.dbc", PrimaryKeys: []string{"id"}, Fields: []FieldMeta{
+        {Name: "id", Type: "uint32"},
+        {Name: "guid", Type: "uint64"},
+    }}
+    schema, err := NewSchema(meta)
+    if err != nil {
+        t.Fatal(err)
+    }
+    guids := []uint64{1, 1<<63 - 1, 1 << 63, 1<<64 - 1}
+    dbc := &DBCFile{Table: NewTable(schema, len(guids)), StringBlock: []byte{0}}
+    for i, guid := range guids {
+        row := dbc.Table.AppendRow()
+        dbc.Table.SetRaw(row, 0, uint64(i+1))
+        dbc.Table.SetRaw(row, 1, guid)
+    }
+
+    // every argument must pass database/sql's default conversion, which drivers
+    // without their own fall back to
+    var db recordingExecer
+    if err := insertRows(&db, postgresDialect{}, "test", dbc, "double", nil); err != nil {
+        t.Fatal(err)
+    }
+    if len(db.args) != 1 || len(db.args[0]) != 2*len(guids) {
+        t.Fatalf("got %d statements, want one with %d arguments", len(db.args), 2*len(guids))
+    }
+    for i, guid := range guids {
+        arg := db.args[0][2*i+1]
+        v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+        if err != nil {
+            t.Errorf("guid %d: %v", guid, err)
+        } else if toUint64(v) != guid {
+            t.Errorf("guid %d bound as %v", guid, v)
+        }
+    }
+}
+
+func TestForeignKeyNone(t *testing.T) {
+    dir := t.TempDir()
+    target := `{"file":"SpellIcon.dbc","primaryKeys":["id"],"fields":[{"name":"id","type":"uint32"}]}`
+    if err := os.WriteFile(filepath.Join(dir, "spellicon.meta.json"), []byte(target), 0644); err != nil {
+        t.Fatal(err)
+    }
+    cfg := &Config{Paths: PathConfig{Meta: dir}}
+
+    meta := &MetaFile{File: "Spell.dbc", PrimaryKeys: []string{"id"}, Fields: []FieldMeta{
+        {Name: "id", Type: "uint32"},
+        {Name: "icon", Type: "uint32", Ref: &FieldRef{Table: "spellicon", None: []int64{0}}},
+        {Name: "alt_icon", Type: "uint32", Ref: &FieldRef{Table: "spellicon", None: []int64{0, 1}}},
+    }}
+    schema, err := NewSchema(meta)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // several none values cannot all come back from NULL, so that ref gets no constraint
+    fks := foreignKeys(cfg, "Spell", schema)
+    if len(fks) != 1 || fks[0].Column != 1 {
+        t.Fatalf("foreign keys = %+v, want one on icon", fks)
+    }
+
+    dbc := &DBCFile{Table: NewTable(schema, 1), StringBlock: []byte{0}}
+    row := dbc.Table.AppendRow()
+    dbc.Table.SetRaw(row, 0, 5)
+    dbc.Table.SetRaw(row, 2, 1)
+    var db recordingExecer
+    if err := insertRows(&db, sqliteDialect{}, "Spell", dbc, "double", fks); err != nil {
+        t.Fatal(err)
+    }
+    args := db.args[0]
+    if args[1] != nil || args[2] != uint32(1) {
+        t.Errorf("bound icon %v and alt_icon %v, want NULL and 1", args[1], args[2])
+    }
+    for c, want := range []uint64{5, 0, 1} {
+        v, err := driver.DefaultParameterConverter.ConvertValue(args[c])
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := sqlValueBits(schema.Columns[c], refValue(meta, schema.Columns[c], v), nil); got != want {
+            t.Errorf("%s read back as %d, want %d", schema.Columns[c].Name, got, want)
+        }
+    }
+}
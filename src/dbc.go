@@ -6,14 +6,19 @@
 package main
 
 import (
-    "crypto/sha256"
+    "bufio"
     "encoding/binary"
     "encoding/json"
     "fmt"
-    "io"
     "math"
     "os"
     "path/filepath"
+    "strings"
+)
+
+const (
+    FormatWDBC = "WDBC"
+    FormatWDB2 = "WDB2"
 )
 
 type DBCHeader struct {
@@ -22,6 +27,40 @@ type DBCHeader struct {
     FieldCount      uint32
     RecordSize      uint32
     StringBlockSize uint32
+
+    // WDB2 only
+    TableHash     uint32
+    Build         uint32
+    Timestamp     uint32
+    MinID         uint32
+    MaxID         uint32
+    Locale        uint32
+    CopyTableSize uint32
+}
+
+// Format returns the container format named by the header magic
+func (h DBCHeader) Format() string {
+    return string(h.Magic[:])
+}
+
+// Size returns the size in bytes of the header on disk
+func (h DBCHeader) Size() int {
+    if h.Format() != FormatWDB2 {
+        return 20
+    }
+    // builds up to 12880 used the short WDB2 header without id range, locale and copy table
+    if h.Build <= 12880 {
+        return 32
+    }
+    return 48
+}
+
+// IndexEntries returns the number of entries in the WDB2 id index and string length arrays
+func (h DBCHeader) IndexEntries() int {
+    if h.Format() != FormatWDB2 || h.MaxID == 0 || h.MaxID < h.MinID {
+        return 0
+    }
+    return int(h.MaxID-h.MinID) + 1
 }
 
 type SortField struct {
@@ -31,12 +70,15 @@ type SortField struct {
 
 type FieldMeta struct {
     Name  string `json:"name"`
-    Type  string `json:"type"` // int32, uint32, float, string, Loc
+    Type  string `json:"type"` // int8-int64, uint8-uint64, float, string, Loc
     Count uint32 `json:"count,omitempty"`
+    Note  string `json:"note,omitempty"` // free-form, e.g. confidence notes written by infer
+    Ref   *FieldRef `json:"ref,omitempty"` // table and field the values refer to
 }
 
 type MetaFile struct {
     File        string      `json:"file"`
+    Format      string      `json:"format,omitempty"` // WDBC (default) or WDB2
     TableName   string      `json:"tableName,omitempty"`
     PrimaryKeys []string    `json:"primaryKeys"`
     UniqueKeys  [][]string  `json:"uniqueKeys,omitempty"` // array of unique key sets
@@ -44,12 +86,23 @@ type MetaFile struct {
     Fields      []FieldMeta `json:"fields"`
 }
 
-type Record map[string]interface{}
-
 type DBCFile struct {
     Header      DBCHeader
-    Records     []Record
+    Table       *Table
     StringBlock []byte
+
+    // WDB2 only
+    IndexTable    []uint32
+    StringLengths []uint16
+    CopyTable     []byte
+}
+
+// FileFormat returns the container format declared by the meta, defaulting to WDBC
+func (m MetaFile) FileFormat() string {
+    if m.Format == "" {
+        return FormatWDBC
+    }
+    return strings.ToUpper(m.Format)
 }
 
 // LoadMeta reads and parses the meta JSON
@@ -65,42 +118,126 @@ func LoadMeta(path string) (MetaFile, error) {
     return meta, nil
 }
 
+// readDBCData returns the contents of a client file. A loose file in paths.base
+// takes precedence; otherwise DBFilesClient\<file> is resolved from the configured
+// MPQ patch chain. The second return value describes where the data came from.
+func readDBCData(cfg *Config, file string) ([]byte, string, error) {
+    path := filepath.Join(cfg.Paths.Base, file)
+    data, err := os.ReadFile(path)
+    if err == nil {
+        return data, path, nil
+    }
+    if !os.IsNotExist(err) || len(cfg.Paths.MPQ) == 0 {
+        return nil, "", fmt.Errorf("failed to read DBC file %s: %w", path, err)
+    }
+
+    set, err := cfg.archives()
+    if err != nil {
+        return nil, "", fmt.Errorf("failed to open MPQ archives: %w", err)
+    }
+    name := mpqDBCDir + filepath.Base(file)
+    data, archive, err := set.ReadFile(name)
+    if err != nil {
+        return nil, "", err
+    }
+    return data, archive + ":" + name, nil
+}
+
+// dbcExists reports whether a client file is available on disk or in the MPQ patch chain
+func dbcExists(cfg *Config, file string) bool {
+    if _, err := os.Stat(filepath.Join(cfg.Paths.Base, file)); err == nil {
+        return true
+    }
+    if len(cfg.Paths.MPQ) == 0 {
+        return false
+    }
+    set, err := cfg.archives()
+    if err != nil {
+        return false
+    }
+    return set.Has(mpqDBCDir + filepath.Base(file))
+}
+
 // LoadDBC reads the DBC file and parses it into memory
 func LoadDBC(dbcPath string, meta MetaFile) (DBCFile, error) {
     data, err := os.ReadFile(dbcPath)
     if err != nil {
         return DBCFile{}, fmt.Errorf("failed to read DBC file %s: %w", dbcPath, err)
     }
-    if len(data) < 20 {
-        return DBCFile{}, fmt.Errorf("file too small to be a valid DBC: %s", dbcPath)
-    }
+    return ParseDBC(data, dbcPath, meta)
+}
 
-    header, err := ParseHeader(data[:20])
+// LoadDBCFile loads the file named by the meta from paths.base or the MPQ archives
+func LoadDBCFile(cfg *Config, meta MetaFile) (DBCFile, error) {
+    data, source, err := readDBCData(cfg, meta.File)
     if err != nil {
         return DBCFile{}, err
     }
+    return ParseDBC(data, source, meta)
+}
+
+// ParseDBC parses the contents of a DBC file; dbcPath is only used in errors
+func ParseDBC(data []byte, dbcPath string, meta MetaFile) (DBCFile, error) {
+    header, err := ParseHeader(data)
+    if err != nil {
+        return DBCFile{}, fmt.Errorf("%w: %s", err, dbcPath)
+    }
+    if header.Format() != meta.FileFormat() {
+        return DBCFile{}, fmt.Errorf("format mismatch: %s is %s but meta declares %s", dbcPath, header.Format(), meta.FileFormat())
+    }
+
+    dbc := DBCFile{Header: header}
+
+    recordsStart := header.Size()
+    if n := header.IndexEntries(); n > 0 {
+        indexEnd := recordsStart + n*4
+        lengthsEnd := indexEnd + n*2
+        if lengthsEnd > len(data) {
+            return DBCFile{}, fmt.Errorf("file too small for WDB2 index arrays: %s", dbcPath)
+        }
+        dbc.IndexTable = make([]uint32, n)
+        for i := range dbc.IndexTable {
+            dbc.IndexTable[i] = binary.LittleEndian.Uint32(data[recordsStart+i*4:])
+        }
+        dbc.StringLengths = make([]uint16, n)
+        for i := range dbc.StringLengths {
+            dbc.StringLengths[i] = binary.LittleEndian.Uint16(data[indexEnd+i*2:])
+        }
+        recordsStart = lengthsEnd
+    }
 
-    recordsStart := 20
     stringBlockStart := recordsStart + int(header.RecordCount*header.RecordSize)
-    if stringBlockStart+int(header.StringBlockSize) > len(data) {
+    stringBlockEnd := stringBlockStart + int(header.StringBlockSize)
+    if stringBlockEnd > len(data) {
         return DBCFile{}, fmt.Errorf("file too small for records + string block: %s", dbcPath)
     }
-    stringBlock := data[stringBlockStart : stringBlockStart+int(header.StringBlockSize)]
+    dbc.StringBlock = data[stringBlockStart:stringBlockEnd]
+
+    if header.CopyTableSize > 0 {
+        if stringBlockEnd+int(header.CopyTableSize) > len(data) {
+            return DBCFile{}, fmt.Errorf("file too small for copy table: %s", dbcPath)
+        }
+        dbc.CopyTable = data[stringBlockEnd : stringBlockEnd+int(header.CopyTableSize)]
+    }
 
-    records, err := ParseRecords(data, recordsStart, header, meta, stringBlock)
+    schema, err := NewSchema(&meta)
     if err != nil {
         return DBCFile{}, err
     }
+    table, err := ParseRecords(data, recordsStart, header, schema)
+    if err != nil {
+        return DBCFile{}, err
+    }
+    dbc.Table = table
 
-    return DBCFile{
-        Header:      header,
-        Records:     records,
-        StringBlock: stringBlock,
-    }, nil
+    return dbc, nil
 }
 
-// ParseHeader parses the DBC header
+// ParseHeader parses a WDBC or WDB2 header from the start of the file
 func ParseHeader(data []byte) (DBCHeader, error) {
+    if len(data) < 20 {
+        return DBCHeader{}, fmt.Errorf("file too small to contain a valid DBC header")
+    }
     header := DBCHeader{
         Magic:           [4]byte{data[0], data[1], data[2], data[3]},
         RecordCount:     binary.LittleEndian.Uint32(data[4:8]),
@@ -108,153 +245,162 @@ func ParseHeader(data []byte) (DBCHeader, error) {
         RecordSize:      binary.LittleEndian.Uint32(data[12:16]),
         StringBlockSize: binary.LittleEndian.Uint32(data[16:20]),
     }
-    if string(header.Magic[:]) != "WDBC" {
+
+    switch header.Format() {
+    case FormatWDBC:
+        return header, nil
+    case FormatWDB2:
+        if len(data) < 32 {
+            return DBCHeader{}, fmt.Errorf("file too small to contain a valid WDB2 header")
+        }
+        header.TableHash = binary.LittleEndian.Uint32(data[20:24])
+        header.Build = binary.LittleEndian.Uint32(data[24:28])
+        header.Timestamp = binary.LittleEndian.Uint32(data[28:32])
+        if header.Size() > 32 {
+            if len(data) < 48 {
+                return DBCHeader{}, fmt.Errorf("file too small to contain a valid WDB2 header")
+            }
+            header.MinID = binary.LittleEndian.Uint32(data[32:36])
+            header.MaxID = binary.LittleEndian.Uint32(data[36:40])
+            header.Locale = binary.LittleEndian.Uint32(data[40:44])
+            header.CopyTableSize = binary.LittleEndian.Uint32(data[44:48])
+        }
+        return header, nil
+    default:
         return DBCHeader{}, fmt.Errorf("invalid DBC file magic: %s", string(header.Magic[:]))
     }
-    return header, nil
 }
 
-// ParseRecords reads all records into memory
-func ParseRecords(data []byte, start int, header DBCHeader, meta MetaFile, stringBlock []byte) ([]Record, error) {
-    // helper to get size in bytes of a single field type element
-    sizeOf := func(typ string) (int, error) {
-        switch typ {
-        case "int32", "uint32", "float", "string":
-            return 4, nil
-        case "uint8", "int8":
-            return 1, nil
-        case "Loc":
-            return 17 * 4, nil
-        default:
-            return 0, fmt.Errorf("unknown field type: %s", typ)
+// encodeHeader serializes the header in the layout selected by its magic
+func encodeHeader(h DBCHeader) []byte {
+    buf := make([]byte, h.Size())
+    copy(buf[0:4], h.Magic[:])
+    binary.LittleEndian.PutUint32(buf[4:8], h.RecordCount)
+    binary.LittleEndian.PutUint32(buf[8:12], h.FieldCount)
+    binary.LittleEndian.PutUint32(buf[12:16], h.RecordSize)
+    binary.LittleEndian.PutUint32(buf[16:20], h.StringBlockSize)
+    if h.Format() == FormatWDB2 {
+        binary.LittleEndian.PutUint32(buf[20:24], h.TableHash)
+        binary.LittleEndian.PutUint32(buf[24:28], h.Build)
+        binary.LittleEndian.PutUint32(buf[28:32], h.Timestamp)
+        if len(buf) > 32 {
+            binary.LittleEndian.PutUint32(buf[32:36], h.MinID)
+            binary.LittleEndian.PutUint32(buf[36:40], h.MaxID)
+            binary.LittleEndian.PutUint32(buf[40:44], h.Locale)
+            binary.LittleEndian.PutUint32(buf[44:48], h.CopyTableSize)
         }
     }
+    return buf
+}
 
-    // compute expected record size from meta
-    expectedRecordSize := 0
-    for _, field := range meta.Fields {
-        elemSize, err := sizeOf(field.Type)
-        if err != nil {
-            return nil, err
-        }
-        repeat := int(field.Count)
-        if repeat == 0 {
-            repeat = 1
-        }
-        expectedRecordSize += elemSize * repeat
+// fieldSize returns the size in bytes of a single element of a field type
+func fieldSize(typ string) (int, error) {
+    switch typ {
+    case "int8", "uint8":
+        return 1, nil
+    case "int16", "uint16":
+        return 2, nil
+    case "int32", "uint32", "float", "string":
+        return 4, nil
+    case "int64", "uint64":
+        return 8, nil
+    case "Loc":
+        return 17 * 4, nil
+    default:
+        return 0, fmt.Errorf("unknown field type: %s", typ)
     }
+}
 
+// ParseRecords reads all records into a table
+func ParseRecords(data []byte, start int, header DBCHeader, schema *Schema) (*Table, error) {
     // quick validation against header.RecordSize
-    if uint32(expectedRecordSize) != header.RecordSize {
-        return nil, fmt.Errorf("record size mismatch: header.RecordSize=%d but meta expects %d (meta mismatch/dbc malformed)", header.RecordSize, expectedRecordSize)
+    if uint32(schema.RecordSize) != header.RecordSize {
+        return nil, fmt.Errorf("record size mismatch: header.RecordSize=%d but meta expects %d (meta mismatch/dbc malformed)", header.RecordSize, schema.RecordSize)
     }
 
     // ensure records area actually fits in data
-    recordsStart := start
     totalRecordsBytes := int(header.RecordCount) * int(header.RecordSize)
-    if recordsStart+totalRecordsBytes > len(data) {
-        return nil, fmt.Errorf("file too small for all records: need %d bytes at offset %d, file length %d", totalRecordsBytes, recordsStart, len(data))
+    if start+totalRecordsBytes > len(data) {
+        return nil, fmt.Errorf("file too small for all records: need %d bytes at offset %d, file length %d", totalRecordsBytes, start, len(data))
     }
 
-    var records []Record
-    for i := uint32(0); i < header.RecordCount; i++ {
-        rec := make(Record)
-        recordOffset := start + int(i*header.RecordSize)
-        offset := 0
-
-        for _, field := range meta.Fields {
-            repeat := int(field.Count)
-            if repeat == 0 {
-                repeat = 1
-            }
+    table := NewTable(schema, int(header.RecordCount))
+    for i := 0; i < int(header.RecordCount); i++ {
+        row := table.AppendRow()
+        rec := data[start+i*int(header.RecordSize):]
 
-            for j := 0; j < repeat; j++ {
-                name := field.Name
-                if field.Count > 1 {
-                    name = fmt.Sprintf("%s_%d", field.Name, j+1)
-                }
-
-                // determine bytes needed for this element
-                elemSize, _ := sizeOf(field.Type)
-                // bounds check before attempting to slice/read
-                if recordOffset+offset+elemSize > len(data) {
-                    return nil, fmt.Errorf("out of bounds reading record %d field %s (recordOffset=%d offset=%d need %d bytes, file len=%d)",
-                        i, name, recordOffset, offset, elemSize, len(data))
-                }
-
-                switch field.Type {
-                case "int32":
-                    val := int32(binary.LittleEndian.Uint32(data[recordOffset+offset : recordOffset+offset+4]))
-                    rec[name] = val
-                    offset += 4
-
-                case "uint32":
-                    val := binary.LittleEndian.Uint32(data[recordOffset+offset : recordOffset+offset+4])
-                    rec[name] = val
-                    offset += 4
-
-                case "uint8":
-                    val := data[recordOffset+offset]
-                    rec[name] = val
-                    offset += 1
-
-                case "float":
-                    bits := binary.LittleEndian.Uint32(data[recordOffset+offset : recordOffset+offset+4])
-                    rec[name] = math.Float32frombits(bits)
-                    offset += 4
-
-                case "string":
-                    strOffset := binary.LittleEndian.Uint32(data[recordOffset+offset : recordOffset+offset+4])
-                    rec[name] = strOffset
-                    offset += 4
-
-                case "Loc":
-                    loc := make([]uint32, 17)
-                    for col := 0; col < 17; col++ {
-                        // inner bounds check (redundant because grouped above, but explicit here for clarity)
-                        if recordOffset+offset+4 > len(data) {
-                            return nil, fmt.Errorf("out of bounds reading Loc element for record %d field %s at col %d", i, name, col)
-                        }
-                        val := binary.LittleEndian.Uint32(data[recordOffset+offset : recordOffset+offset+4])
-                        loc[col] = val
-                        offset += 4
-                    }
-                    rec[name] = loc
-
-                default:
-                    return nil, fmt.Errorf("unknown field type: %s", field.Type)
-                }
-            }
+        for c, col := range schema.Columns {
+            table.SetRaw(row, c, getColumn(rec, col))
         }
+    }
 
-        // sanity: ensure we've consumed exactly the expected number of bytes for this record
-        if offset != expectedRecordSize {
-            return nil, fmt.Errorf("parsed record %d consumed %d bytes but expected %d", i, offset, expectedRecordSize)
-        }
+    return table, nil
+}
+
+// encodeRecord writes one row into buf in the on-disk record layout
+func encodeRecord(buf []byte, table *Table, row int) {
+    for c, col := range table.Schema.Columns {
+        putColumn(buf, col, table.Raw(row, c))
+    }
+}
+
+// putColumn stores the raw bits of a value at the column offset of a record
+func putColumn(buf []byte, col Column, raw uint64) {
+    switch col.Size {
+    case 1:
+        buf[col.Offset] = uint8(raw)
+    case 2:
+        binary.LittleEndian.PutUint16(buf[col.Offset:], uint16(raw))
+    case 4:
+        binary.LittleEndian.PutUint32(buf[col.Offset:], uint32(raw))
+    case 8:
+        binary.LittleEndian.PutUint64(buf[col.Offset:], raw)
+    }
+}
+
+// getColumn reads the raw bits of a value at the column offset of a record
+func getColumn(buf []byte, col Column) uint64 {
+    switch col.Size {
+    case 1:
+        return uint64(buf[col.Offset])
+    case 2:
+        return uint64(binary.LittleEndian.Uint16(buf[col.Offset:]))
+    case 4:
+        return uint64(binary.LittleEndian.Uint32(buf[col.Offset:]))
+    default:
+        return binary.LittleEndian.Uint64(buf[col.Offset:])
+    }
+}
 
-        records = append(records, rec)
+// resolveDBCFile finds the file name for a DBC name, preferring the file named by its meta
+func resolveDBCFile(dbcName string, cfg *Config) string {
+    metaPath := filepath.Join(cfg.Paths.Meta, dbcName+".meta.json")
+    if meta, err := LoadMeta(metaPath); err == nil && meta.File != "" {
+        return meta.File
     }
 
-    return records, nil
+    for _, ext := range []string{".dbc", ".db2"} {
+        if dbcExists(cfg, dbcName+ext) {
+            return dbcName + ext
+        }
+    }
+    return dbcName + ".dbc"
 }
 
 func ReadDBCHeader(dbcName string, cfg *Config) (DBCHeader, error) {
-    dbcPath := filepath.Join(cfg.Paths.Base, dbcName+".dbc")
+    file := resolveDBCFile(dbcName, cfg)
 
     // Check existence
-    if _, err := os.Stat(dbcPath); os.IsNotExist(err) {
-        return DBCHeader{}, fmt.Errorf("DBC file not found: %s", dbcPath)
+    if !dbcExists(cfg, file) {
+        return DBCHeader{}, fmt.Errorf("DBC file not found: %s", file)
     }
 
-    data, err := os.ReadFile(dbcPath)
+    data, _, err := readDBCData(cfg, file)
     if err != nil {
         return DBCHeader{}, fmt.Errorf("failed to read DBC file: %w", err)
     }
-    if len(data) < 20 {
-        return DBCHeader{}, fmt.Errorf("file too small to contain a valid DBC header")
-    }
 
-    header, err := ParseHeader(data[:20])
+    header, err := ParseHeader(data)
     if err != nil {
         return DBCHeader{}, err
     }
@@ -263,12 +409,8 @@ func ReadDBCHeader(dbcName string, cfg *Config) (DBCHeader, error) {
 }
 
 func ReadDBCFile(dbcName string, cfg *Config) (*DBCFile, *MetaFile, error) {
-    dbcPath := filepath.Join(cfg.Paths.Base, dbcName+".dbc")
     metaPath := filepath.Join(cfg.Paths.Meta, dbcName+".meta.json")
 
-    if _, err := os.Stat(dbcPath); os.IsNotExist(err) {
-        return nil, nil, fmt.Errorf("DBC file not found: %s", dbcPath)
-    }
     if _, err := os.Stat(metaPath); os.IsNotExist(err) {
         return nil, nil, fmt.Errorf("Meta file not found: %s", metaPath)
     }
@@ -278,7 +420,11 @@ func ReadDBCFile(dbcName string, cfg *Config) (*DBCFile, *MetaFile, error) {
         return nil, nil, fmt.Errorf("failed to load meta: %w", err)
     }
 
-    dbc, err := LoadDBC(dbcPath, meta)
+    if !dbcExists(cfg, meta.File) {
+        return nil, nil, fmt.Errorf("DBC file not found: %s", meta.File)
+    }
+
+    dbc, err := LoadDBCFile(cfg, meta)
     if err != nil {
         return nil, nil, fmt.Errorf("failed to load dbc: %w", err)
     }
@@ -287,157 +433,219 @@ func ReadDBCFile(dbcName string, cfg *Config) (*DBCFile, *MetaFile, error) {
 }
 
 // WriteDBC writes a DBC file from memory
-func WriteDBC(dbc *DBCFile, meta *MetaFile, outPath string) error {
+func WriteDBC(dbc *DBCFile, outPath string) error {
     outFile, err := os.Create(outPath)
     if err != nil {
         return err
     }
     defer outFile.Close()
+    out := bufio.NewWriter(outFile)
 
     // Write header
-    headerBuf := make([]byte, 20)
-    copy(headerBuf[0:4], dbc.Header.Magic[:])
-    binary.LittleEndian.PutUint32(headerBuf[4:8], dbc.Header.RecordCount)
-    binary.LittleEndian.PutUint32(headerBuf[8:12], dbc.Header.FieldCount)
-    binary.LittleEndian.PutUint32(headerBuf[12:16], dbc.Header.RecordSize)
-    binary.LittleEndian.PutUint32(headerBuf[16:20], dbc.Header.StringBlockSize)
-    if _, err := outFile.Write(headerBuf); err != nil {
+    if _, err := out.Write(encodeHeader(dbc.Header)); err != nil {
         return err
     }
 
-    // Write records
-    recordData := make([]byte, dbc.Header.RecordCount*dbc.Header.RecordSize)
-    offset := 0
-
-    for _, rec := range dbc.Records {
-        for _, field := range meta.Fields {
-            repeat := int(field.Count)
-            if repeat == 0 {
-                repeat = 1
-            }
+    // Write WDB2 id index and string length arrays
+    if n := dbc.Header.IndexEntries(); n > 0 {
+        if len(dbc.IndexTable) != n || len(dbc.StringLengths) != n {
+            return fmt.Errorf("WDB2 index arrays have %d/%d entries but header id range needs %d", len(dbc.IndexTable), len(dbc.StringLengths), n)
+        }
+        if _, err := out.Write(encodeWDB2Index(dbc.IndexTable, dbc.StringLengths)); err != nil {
+            return err
+        }
+    }
 
-            for j := 0; j < repeat; j++ {
-                name := field.Name
-                if field.Count > 1 {
-                    name = fmt.Sprintf("%s_%d", field.Name, j+1)
-                }
-
-                switch field.Type {
-                case "int32":
-                    binary.LittleEndian.PutUint32(recordData[offset:offset+4],uint32(rec[name].(int32)))
-                    offset += 4
-                case "uint32":
-                    binary.LittleEndian.PutUint32(recordData[offset:offset+4],rec[name].(uint32))
-                    offset += 4
-                case "uint8":
-                    recordData[offset] = rec[name].(uint8)
-                    offset += 1
-                case "float":
-                    bits := math.Float32bits(rec[name].(float32))
-                    binary.LittleEndian.PutUint32(recordData[offset:offset+4],bits)
-                    offset += 4
-                case "string":
-                    binary.LittleEndian.PutUint32(recordData[offset:offset+4],rec[name].(uint32))
-                    offset += 4
-
-                case "Loc":
-                    loc := rec[name].([]uint32)
-                    for _, v := range loc {
-                        binary.LittleEndian.PutUint32(recordData[offset:offset+4], v)
-                        offset += 4
-                    }
-                }
-            }
+    // Write records
+    record := make([]byte, dbc.Table.Schema.RecordSize)
+    for row := 0; row < dbc.Table.Len(); row++ {
+        encodeRecord(record, dbc.Table, row)
+        if _, err := out.Write(record); err != nil {
+            return err
         }
     }
 
-    if _, err := outFile.Write(recordData); err != nil {
+    // Write string block
+    if _, err := out.Write(dbc.StringBlock); err != nil {
         return err
     }
 
-    // Write string block
-    if _, err := outFile.Write(dbc.StringBlock); err != nil {
+    // Write WDB2 copy table
+    if _, err := out.Write(dbc.CopyTable); err != nil {
         return err
     }
 
-    return nil
+    if err := out.Flush(); err != nil {
+        return err
+    }
+    return outFile.Close()
 }
 
-// --- Utility Functions ---
-func readString(stringBlock []byte, offset uint32) string {
-    if offset >= uint32(len(stringBlock)) {
-        return ""
+// encodeWDB2Index serializes the id index followed by the string length array
+func encodeWDB2Index(index []uint32, lengths []uint16) []byte {
+    buf := make([]byte, len(index)*4+len(lengths)*2)
+    for i, v := range index {
+        binary.LittleEndian.PutUint32(buf[i*4:], v)
     }
-    end := offset
-    for end < uint32(len(stringBlock)) && stringBlock[end] != 0 {
-        end++
+    for i, v := range lengths {
+        binary.LittleEndian.PutUint16(buf[len(index)*4+i*2:], v)
     }
-    return string(stringBlock[offset:end])
+    return buf
 }
 
-func PrintRecord(rec Record, meta *MetaFile, stringBlock []byte) {
-    for _, field := range meta.Fields {
-        repeat := int(field.Count)
-        if repeat == 0 {
-            repeat = 1
-        }
+// FinishHeader sets the header counts of a table built in memory. For WDB2 the
+// table hash, build, timestamp, locale and copy table are taken from prev, if
+// given, and the id index is rebuilt. Without a build from prev the short header
+// is written, which has no room for an id index or copy table.
+func FinishHeader(dbc *DBCFile, prev *DBCFile) error {
+    schema := dbc.Table.Schema
+    dbc.Header.RecordCount = uint32(dbc.Table.Len())
+    dbc.Header.FieldCount = uint32(schema.FieldCount)
+    dbc.Header.RecordSize = uint32(schema.RecordSize)
+    dbc.Header.StringBlockSize = uint32(len(dbc.StringBlock))
+    if dbc.Header.Format() != FormatWDB2 {
+        return nil
+    }
+
+    if prev != nil {
+        dbc.Header.TableHash, dbc.Header.Build = prev.Header.TableHash, prev.Header.Build
+        dbc.Header.Timestamp, dbc.Header.Locale = prev.Header.Timestamp, prev.Header.Locale
+        dbc.CopyTable = prev.CopyTable
+    }
+    if dbc.Header.Size() <= 32 {
+        dbc.Header.MinID, dbc.Header.MaxID, dbc.Header.Locale = 0, 0, 0
+        dbc.IndexTable, dbc.StringLengths, dbc.CopyTable = nil, nil, nil
+        dbc.Header.CopyTableSize = 0
+        return nil
+    }
+    dbc.Header.CopyTableSize = uint32(len(dbc.CopyTable))
+    return BuildWDB2Index(dbc, prev)
+}
 
-        for j := 0; j < repeat; j++ {
-            name := field.Name
-            if field.Count > 1 {
-                name = fmt.Sprintf("%s_%d", field.Name, j+1)
-            }
+// BuildWDB2Index recomputes the WDB2 id range and index arrays from the records.
+// The arrays in prev are reused verbatim when the id range and record count are
+// unchanged so unmodified tables round-trip byte for byte.
+func BuildWDB2Index(dbc *DBCFile, prev *DBCFile) error {
+    table := dbc.Table
+    if len(table.Schema.Columns) == 0 {
+        return fmt.Errorf("meta has no fields")
+    }
+    if idCol := table.Schema.Columns[0]; idCol.Type != "int32" && idCol.Type != "uint32" {
+        return fmt.Errorf("WDB2 id field %s must be int32 or uint32, got %s", idCol.Name, idCol.Type)
+    }
 
-            val, exists := rec[name]
-            if !exists {
-                fmt.Printf("  %s: <missing>\n", name)
-                continue
-            }
+    ids := make([]uint32, table.Len())
+    strLens := make([]uint16, table.Len())
+    for row := range ids {
+        ids[row] = uint32(table.Raw(row, 0))
+        strLens[row] = clampUint16(rowStringLength(dbc, row))
+    }
 
-            switch field.Type {
-            case "string":
-                offset := val.(uint32)
-                str := readString(stringBlock, offset)
-                fmt.Printf("  %s: %v (\"%s\")\n", name, offset, str)
-            case "Loc":
-                locArr := val.([]uint32)
-                for i, lang := range locLangs {
-                    if i < len(locArr)-1 {
-                        str := readString(stringBlock, locArr[i])
-                        fmt.Printf("  %s_%s: %v (\"%s\")\n", name, lang, locArr[i], str)
-                    } else {
-                        fmt.Printf("  %s_flags: %v\n", name, locArr[i])
-                    }
-                }
-            default:
-                fmt.Printf("  %s: %v\n", name, val)
-            }
+    minID, maxID, index, lengths, err := wdb2Index(ids, strLens, prev)
+    if err != nil {
+        return err
+    }
+    dbc.Header.MinID, dbc.Header.MaxID, dbc.IndexTable, dbc.StringLengths = minID, maxID, index, lengths
+    return nil
+}
+
+// rowStringLength sums the text length of the string columns of a record
+func rowStringLength(dbc *DBCFile, row int) int {
+    total := 0
+    for c, col := range dbc.Table.Schema.Columns {
+        if col.Type == "string" {
+            total += len(dbc.Text(row, c))
         }
     }
+    return total
 }
 
-func compareFiles(path1, path2 string) (bool, error) {
-    f1, err := os.Open(path1)
-    if err != nil {
-        return false, err
+// maxWDB2IndexEntries bounds the id range of a WDB2 index; client tables stay far below it,
+// so a larger range means a bad id such as a negative int32
+const maxWDB2IndexEntries = 1 << 22
+
+// wdb2Index builds the id range and index arrays for records with the given ids and
+// summed string lengths. Files whose previous header had no index get none.
+// Duplicate ids and id ranges above maxWDB2IndexEntries are errors.
+func wdb2Index(ids []uint32, strLens []uint16, prev *DBCFile) (uint32, uint32, []uint32, []uint16, error) {
+    if len(ids) == 0 || (prev != nil && prev.Header.MaxID == 0) {
+        return 0, 0, nil, nil, nil
     }
-    defer f1.Close()
 
-    f2, err := os.Open(path2)
-    if err != nil {
-        return false, err
+    minID, maxID := idRange(ids)
+    if uint64(maxID-minID) >= maxWDB2IndexEntries {
+        return 0, 0, nil, nil, fmt.Errorf("record ids span %d-%d, too wide for a WDB2 index", minID, maxID)
     }
-    defer f2.Close()
+    n := int(maxID-minID) + 1
 
-    h1 := sha256.New()
-    h2 := sha256.New()
+    seen := make([]bool, n)
+    for row, id := range ids {
+        if seen[id-minID] {
+            return 0, 0, nil, nil, fmt.Errorf("duplicate id %d in record %d", id, row)
+        }
+        seen[id-minID] = true
+    }
 
-    if _, err := io.Copy(h1, f1); err != nil {
-        return false, err
+    if prev != nil && prev.Header.MinID == minID && prev.Header.MaxID == maxID &&
+        prev.Header.RecordCount == uint32(len(ids)) &&
+        len(prev.IndexTable) == n && len(prev.StringLengths) == n {
+        return minID, maxID, prev.IndexTable, prev.StringLengths, nil
     }
-    if _, err := io.Copy(h2, f2); err != nil {
-        return false, err
+
+    index := make([]uint32, n)
+    lengths := make([]uint16, n)
+    for row, id := range ids {
+        index[id-minID] = uint32(row)
+        // the client only uses this as an allocation hint, so the summed text length is sufficient
+        lengths[id-minID] = strLens[row]
+    }
+    return minID, maxID, index, lengths, nil
+}
+
+// idRange returns the smallest and largest id
+func idRange(ids []uint32) (uint32, uint32) {
+    minID, maxID := ids[0], ids[0]
+    for _, id := range ids {
+        if id < minID {
+            minID = id
+        }
+        if id > maxID {
+            maxID = id
+        }
     }
+    return minID, maxID
+}
 
-    return string(h1.Sum(nil)) == string(h2.Sum(nil)), nil
-}
\ No newline at end of file
+func clampUint16(n int) uint16 {
+    if n > math.MaxUint16 {
+        return math.MaxUint16
+    }
+    return uint16(n)
+}
+
+// --- Utility Functions ---
+func readString(stringBlock []byte, offset uint32) string {
+    if offset >= uint32(len(stringBlock)) {
+        return ""
+    }
+    end := offset
+    for end < uint32(len(stringBlock)) && stringBlock[end] != 0 {
+        end++
+    }
+    return string(stringBlock[offset:end])
+}
+
+// Text resolves a string column of a row against the string block
+func (d *DBCFile) Text(row, col int) string {
+    return readString(d.StringBlock, uint32(d.Table.Raw(row, col)))
+}
+
+func PrintRecord(dbc *DBCFile, row int) {
+    for c, col := range dbc.Table.Schema.Columns {
+        if col.Type == "string" {
+            fmt.Printf("  %s: %v (\"%s\")\n", col.Name, dbc.Table.Raw(row, c), dbc.Text(row, c))
+        } else {
+            fmt.Printf("  %s: %v\n", col.Name, dbc.Table.Value(row, c))
+        }
+    }
+}
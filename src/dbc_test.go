@@ -0,0 +1,150 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+var wdb2TestMeta = MetaFile{
+    File:        "Test.db2",
+    Format:      "WDB2",
+    PrimaryKeys: []string{"id"},
+    Fields: []FieldMeta{
+        {Name: "id", Type: "uint32"},
+        {Name: "name", Type: "string"},
+        {Name: "value", Type: "int32"},
+    },
+}
+
+// wdb2TestDBC builds a WDB2 table with the given ids, naming each record after its id
+func wdb2TestDBC(t *testing.T, ids ...uint32) *DBCFile {
+    t.Helper()
+    meta := wdb2TestMeta
+    schema, err := NewSchema(&meta)
+    if err != nil {
+        t.Fatal(err)
+    }
+    dbc := &DBCFile{Table: NewTable(schema, len(ids))}
+    copy(dbc.Header.Magic[:], FormatWDB2)
+    strs := newStringTable(nil)
+    for _, id := range ids {
+        row := dbc.Table.AppendRow()
+        dbc.Table.SetRaw(row, 0, uint64(id))
+        dbc.Table.SetRaw(row, 1, uint64(strs.offset(strings.Repeat("x", int(id%4)))))
+        dbc.Table.SetRaw(row, 2, uint64(uint32(int32(-int(id)))))
+    }
+    dbc.StringBlock = strs.block
+    return dbc
+}
+
+// wdb2Prev is the header of an original file with the long WDB2 header
+func wdb2Prev(build uint32) *DBCFile {
+    prev := &DBCFile{CopyTable: []byte{9, 0, 0, 0, 3, 0, 0, 0}}
+    copy(prev.Header.Magic[:], FormatWDB2)
+    prev.Header.TableHash, prev.Header.Build, prev.Header.Timestamp, prev.Header.Locale = 0x1234, build, 77, 8
+    prev.Header.MaxID = 1
+    return prev
+}
+
+// writeAndLoad writes a DBC to a temporary file and parses it back
+func writeAndLoad(t *testing.T, dbc *DBCFile) (DBCFile, []byte) {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "out.db2")
+    if err := WriteDBC(dbc, path); err != nil {
+        t.Fatal(err)
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    loaded, err := ParseDBC(data, path, wdb2TestMeta)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return loaded, data
+}
+
+func TestWDB2RoundTrip(t *testing.T) {
+    dbc := wdb2TestDBC(t, 7, 3, 5)
+    if err := FinishHeader(dbc, wdb2Prev(15595)); err != nil {
+        t.Fatal(err)
+    }
+    if h := dbc.Header; h.MinID != 3 || h.MaxID != 7 || h.Size() != 48 || h.CopyTableSize != 8 {
+        t.Fatalf("header = %+v", h)
+    }
+    // ids 3, 5 and 7 are rows 1, 2 and 0; gaps point at row 0 with no string length
+    if want := []uint32{1, 0, 2, 0, 0}; !reflect.DeepEqual(dbc.IndexTable, want) {
+        t.Errorf("index = %v, want %v", dbc.IndexTable, want)
+    }
+    if want := []uint16{3, 0, 1, 0, 3}; !reflect.DeepEqual(dbc.StringLengths, want) {
+        t.Errorf("string lengths = %v, want %v", dbc.StringLengths, want)
+    }
+
+    loaded, data := writeAndLoad(t, dbc)
+    if loaded.Header != dbc.Header {
+        t.Errorf("header = %+v, want %+v", loaded.Header, dbc.Header)
+    }
+    if !reflect.DeepEqual(loaded.IndexTable, dbc.IndexTable) || !reflect.DeepEqual(loaded.StringLengths, dbc.StringLengths) {
+        t.Errorf("index arrays = %v %v", loaded.IndexTable, loaded.StringLengths)
+    }
+    if !bytes.Equal(loaded.CopyTable, dbc.CopyTable) {
+        t.Errorf("copy table = %v", loaded.CopyTable)
+    }
+    for row := 0; row < dbc.Table.Len(); row++ {
+        if loaded.Table.Uint(row, 0) != dbc.Table.Uint(row, 0) || loaded.Text(row, 1) != dbc.Text(row, 1) ||
+            loaded.Table.Int(row, 2) != dbc.Table.Int(row, 2) {
+            t.Errorf("record %d differs", row)
+        }
+    }
+
+    // an unchanged table reuses the arrays of the original and writes the same bytes
+    if err := FinishHeader(&loaded, &loaded); err != nil {
+        t.Fatal(err)
+    }
+    if _, again := writeAndLoad(t, &loaded); !bytes.Equal(again, data) {
+        t.Error("rewritten file differs")
+    }
+}
+
+func TestWDB2ShortHeader(t *testing.T) {
+    dbc := wdb2TestDBC(t, 1, 2)
+    if err := FinishHeader(dbc, wdb2Prev(12340)); err != nil {
+        t.Fatal(err)
+    }
+    if dbc.Header.Size() != 32 || dbc.IndexTable != nil || dbc.CopyTable != nil || dbc.Header.MaxID != 0 {
+        t.Fatalf("short header kept index fields: %+v", dbc.Header)
+    }
+    loaded, data := writeAndLoad(t, dbc)
+    if want := 32 + 2*dbc.Table.Schema.RecordSize + len(dbc.StringBlock); len(data) != want {
+        t.Errorf("file is %d bytes, want %d", len(data), want)
+    }
+    if loaded.Table.Len() != 2 || loaded.Table.Uint(1, 0) != 2 {
+        t.Errorf("records not read back")
+    }
+}
+
+func TestWDB2IndexErrors(t *testing.T) {
+    tests := []struct {
+        ids []uint32
+        err string
+    }{
+        {[]uint32{5, 6, 5}, "duplicate id 5"},
+        {[]uint32{1, 0xFFFFFFFF}, "too wide"},
+        {[]uint32{1, 4000000000}, "too wide"},
+    }
+    for _, tt := range tests {
+        err := FinishHeader(wdb2TestDBC(t, tt.ids...), wdb2Prev(15595))
+        if err == nil || !strings.Contains(err.Error(), tt.err) {
+            t.Errorf("ids %v: got error %v, want %q", tt.ids, err, tt.err)
+        }
+    }
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+)
+
+// DBCWriter streams records to disk without holding the table in memory.
+// The header and the WDB2 index arrays are reserved when the file is created
+// and patched in by Close, after the string block has been appended.
+type DBCWriter struct {
+    header DBCHeader
+    schema *Schema
+    prev   *DBCFile // WDB2 header and index arrays of the previous version, if any
+
+    path string
+    file *os.File
+    out  *bufio.Writer
+
+    record        []byte
+    rows          int
+    stringBlock   []byte
+    stringOffsets map[string]uint32
+
+    // WDB2 only
+    ids     []uint32
+    strLens []uint16
+}
+
+// NewDBCWriter creates a writer for outPath. The header supplies the magic and,
+// for WDB2, the table hash, build, timestamp, locale and the id range to reserve
+// index space for. prev may carry the previous WDB2 index arrays and copy table.
+func NewDBCWriter(outPath string, schema *Schema, header DBCHeader, prev *DBCFile) (*DBCWriter, error) {
+    if header.Format() == FormatWDB2 && len(schema.Columns) > 0 {
+        if t := schema.Columns[0].Type; t != "int32" && t != "uint32" {
+            return nil, fmt.Errorf("WDB2 id field %s must be int32 or uint32, got %s", schema.Columns[0].Name, t)
+        }
+    }
+    if prev != nil {
+        header.CopyTableSize = uint32(len(prev.CopyTable))
+    }
+
+    file, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
+    if err != nil {
+        return nil, err
+    }
+
+    w := &DBCWriter{
+        header:        header,
+        schema:        schema,
+        prev:          prev,
+        path:          outPath,
+        file:          file,
+        out:           bufio.NewWriter(file),
+        record:        make([]byte, schema.RecordSize),
+        stringBlock:   []byte{0}, // first byte must be null
+        stringOffsets: map[string]uint32{"": 0},
+    }
+
+    // reserve the header and index arrays, they are written by Close
+    reserved := header.Size() + header.IndexEntries()*6
+    if _, err := w.out.Write(make([]byte, reserved)); err != nil {
+        w.Abort()
+        return nil, err
+    }
+    return w, nil
+}
+
+// Record returns the zeroed buffer for the next record
+func (w *DBCWriter) Record() []byte {
+    for i := range w.record {
+        w.record[i] = 0
+    }
+    return w.record
+}
+
+// AddString returns the string block offset of s, appending it if new
+func (w *DBCWriter) AddString(s string) uint32 {
+    return getStringOffset(s, &w.stringBlock, w.stringOffsets)
+}
+
+// WriteRecord writes the record buffer returned by Record
+func (w *DBCWriter) WriteRecord() error {
+    if w.header.Format() == FormatWDB2 && len(w.schema.Columns) > 0 {
+        w.ids = append(w.ids, uint32(getColumn(w.record, w.schema.Columns[0])))
+        total := 0
+        for _, col := range w.schema.Columns {
+            if col.Type == "string" {
+                total += len(readString(w.stringBlock, uint32(getColumn(w.record, col))))
+            }
+        }
+        w.strLens = append(w.strLens, clampUint16(total))
+    }
+
+    if _, err := w.out.Write(w.record); err != nil {
+        return err
+    }
+    w.rows++
+    return nil
+}
+
+// Close appends the string block, patches the header and moves the file into place
+func (w *DBCWriter) Close() error {
+    if err := w.finish(); err != nil {
+        w.Abort()
+        return err
+    }
+    return os.Rename(w.file.Name(), w.path)
+}
+
+func (w *DBCWriter) finish() error {
+    if _, err := w.out.Write(w.stringBlock); err != nil {
+        return err
+    }
+    if w.prev != nil {
+        if _, err := w.out.Write(w.prev.CopyTable); err != nil {
+            return err
+        }
+    }
+    if err := w.out.Flush(); err != nil {
+        return err
+    }
+
+    h := w.header
+    h.RecordCount = uint32(w.rows)
+    h.FieldCount = uint32(w.schema.FieldCount)
+    h.RecordSize = uint32(w.schema.RecordSize)
+    h.StringBlockSize = uint32(len(w.stringBlock))
+
+    patch := []byte{}
+    if h.Format() == FormatWDB2 && h.Size() > 32 {
+        reserved := h.IndexEntries()
+        minID, maxID, index, lengths, err := wdb2Index(w.ids, w.strLens, w.prev)
+        if err != nil {
+            return err
+        }
+        if reserved > 0 || len(index) > 0 {
+            if minID != h.MinID || maxID != h.MaxID {
+                return fmt.Errorf("record ids span %d-%d but %d-%d was reserved", minID, maxID, h.MinID, h.MaxID)
+            }
+            patch = encodeWDB2Index(index, lengths)
+        }
+        h.MinID, h.MaxID = minID, maxID
+    }
+    patch = append(encodeHeader(h), patch...)
+
+    if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+        return err
+    }
+    if _, err := w.file.Write(patch); err != nil {
+        return err
+    }
+    return w.file.Close()
+}
+
+// Abort discards a file that has not been closed
+func (w *DBCWriter) Abort() {
+    w.file.Close()
+    os.Remove(w.file.Name())
+}
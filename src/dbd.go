@@ -0,0 +1,364 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// DBDColumn is an entry of the COLUMNS section of a WoWDBDefs .dbd file
+type DBDColumn struct {
+    Type         string // int, float, string or locstring
+    Name         string
+    ForeignTable string // from int<Table::Field>
+    ForeignField string
+    Verified     bool // false if the name is marked with ?
+}
+
+// DBDEntry is a column as laid out in one definition block
+type DBDEntry struct {
+    Name        string
+    Size        int // integer bit size, 0 if not given
+    Unsigned    bool
+    Count       int // array length, 0 for scalars
+    Annotations []string
+    Comment     string
+}
+
+// DBDDefinition is a block of BUILD/LAYOUT lines followed by its column layout
+type DBDDefinition struct {
+    Builds  []string // single builds or ranges like 3.0.1.8303-3.3.5.12340
+    Layouts []string
+    Entries []DBDEntry
+}
+
+// DBDFile is a parsed .dbd file
+type DBDFile struct {
+    Columns     map[string]DBDColumn
+    Definitions []DBDDefinition
+}
+
+// ParseDBD reads a WoWDBDefs definition file
+func ParseDBD(r io.Reader) (*DBDFile, error) {
+    dbd := &DBDFile{Columns: map[string]DBDColumn{}}
+    scanner := bufio.NewScanner(r)
+
+    inColumns := false
+    var def *DBDDefinition
+    lineNo := 0
+    for scanner.Scan() {
+        lineNo++
+        line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+
+        if line == "" {
+            if def != nil {
+                dbd.Definitions = append(dbd.Definitions, *def)
+                def = nil
+            }
+            continue
+        }
+        if line == "COLUMNS" {
+            inColumns = true
+            continue
+        }
+
+        if inColumns {
+            if strings.HasPrefix(line, "LAYOUT ") || strings.HasPrefix(line, "BUILD ") {
+                inColumns = false
+            } else {
+                col, err := parseDBDColumn(line)
+                if err != nil {
+                    return nil, fmt.Errorf("line %d: %w", lineNo, err)
+                }
+                dbd.Columns[col.Name] = col
+                continue
+            }
+        }
+
+        if def == nil {
+            def = &DBDDefinition{}
+        }
+        switch {
+        case strings.HasPrefix(line, "BUILD "):
+            for _, b := range strings.Split(strings.TrimPrefix(line, "BUILD "), ",") {
+                def.Builds = append(def.Builds, strings.TrimSpace(b))
+            }
+        case strings.HasPrefix(line, "LAYOUT "):
+            for _, l := range strings.Split(strings.TrimPrefix(line, "LAYOUT "), ",") {
+                def.Layouts = append(def.Layouts, strings.TrimSpace(l))
+            }
+        case strings.HasPrefix(line, "COMMENT "):
+        default:
+            entry, err := parseDBDEntry(line)
+            if err != nil {
+                return nil, fmt.Errorf("line %d: %w", lineNo, err)
+            }
+            if _, ok := dbd.Columns[entry.Name]; !ok {
+                return nil, fmt.Errorf("line %d: column %s is not defined in COLUMNS", lineNo, entry.Name)
+            }
+            def.Entries = append(def.Entries, entry)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    if def != nil {
+        dbd.Definitions = append(dbd.Definitions, *def)
+    }
+    return dbd, nil
+}
+
+// parseDBDColumn parses "int<Map::ID> MapID // comment"
+func parseDBDColumn(line string) (DBDColumn, error) {
+    if i := strings.Index(line, "//"); i >= 0 {
+        line = strings.TrimSpace(line[:i])
+    }
+    parts := strings.Fields(line)
+    if len(parts) != 2 {
+        return DBDColumn{}, fmt.Errorf("invalid column definition %q", line)
+    }
+
+    col := DBDColumn{Type: parts[0], Name: parts[1], Verified: true}
+    if i := strings.Index(col.Type, "<"); i >= 0 {
+        ref := strings.TrimSuffix(col.Type[i+1:], ">")
+        col.Type = col.Type[:i]
+        table, field, ok := strings.Cut(ref, "::")
+        if !ok {
+            return DBDColumn{}, fmt.Errorf("invalid foreign key %q", ref)
+        }
+        col.ForeignTable, col.ForeignField = table, field
+    }
+    if strings.HasSuffix(col.Name, "?") {
+        col.Name = strings.TrimSuffix(col.Name, "?")
+        col.Verified = false
+    }
+
+    switch col.Type {
+    case "int", "float", "string", "locstring":
+    default:
+        return DBDColumn{}, fmt.Errorf("unknown column type %q", col.Type)
+    }
+    return col, nil
+}
+
+// parseDBDEntry parses "$id,relation$Name<u32>[3] // comment"
+func parseDBDEntry(line string) (DBDEntry, error) {
+    var entry DBDEntry
+    if i := strings.Index(line, "//"); i >= 0 {
+        entry.Comment = strings.TrimSpace(line[i+2:])
+        line = strings.TrimSpace(line[:i])
+    }
+
+    if strings.HasPrefix(line, "$") {
+        end := strings.Index(line[1:], "$")
+        if end < 0 {
+            return DBDEntry{}, fmt.Errorf("unterminated annotation in %q", line)
+        }
+        entry.Annotations = strings.Split(line[1:end+1], ",")
+        line = line[end+2:]
+    }
+
+    if i := strings.Index(line, "["); i >= 0 {
+        n, err := strconv.Atoi(strings.TrimSuffix(line[i+1:], "]"))
+        if err != nil {
+            return DBDEntry{}, fmt.Errorf("invalid array size in %q", line)
+        }
+        entry.Count = n
+        line = line[:i]
+    }
+
+    if i := strings.Index(line, "<"); i >= 0 {
+        size := strings.TrimSuffix(line[i+1:], ">")
+        if strings.HasPrefix(size, "u") {
+            entry.Unsigned = true
+            size = size[1:]
+        }
+        n, err := strconv.Atoi(size)
+        if err != nil {
+            return DBDEntry{}, fmt.Errorf("invalid int size in %q", line)
+        }
+        entry.Size = n
+        line = line[:i]
+    }
+
+    entry.Name = line
+    if entry.Name == "" {
+        return DBDEntry{}, fmt.Errorf("missing column name")
+    }
+    return entry, nil
+}
+
+// HasAnnotation reports whether the entry carries $name$
+func (e DBDEntry) HasAnnotation(name string) bool {
+    for _, a := range e.Annotations {
+        if a == name {
+            return true
+        }
+    }
+    return false
+}
+
+// Find returns the definition whose BUILD lines cover the given build
+func (d *DBDFile) Find(build string) (*DBDDefinition, error) {
+    target, err := parseBuild(build)
+    if err != nil {
+        return nil, err
+    }
+    for i := range d.Definitions {
+        for _, b := range d.Definitions[i].Builds {
+            lo, hi, isRange := strings.Cut(b, "-")
+            if !isRange {
+                hi = lo
+            }
+            from, err := parseBuild(lo)
+            if err != nil {
+                return nil, err
+            }
+            to, err := parseBuild(hi)
+            if err != nil {
+                return nil, err
+            }
+            if compareBuild(target, from) >= 0 && compareBuild(target, to) <= 0 {
+                return &d.Definitions[i], nil
+            }
+        }
+    }
+    return nil, fmt.Errorf("no definition for build %s", build)
+}
+
+// parseBuild parses a build string like 3.3.5.12340
+func parseBuild(s string) ([4]int, error) {
+    var b [4]int
+    parts := strings.Split(strings.TrimSpace(s), ".")
+    if len(parts) != 4 {
+        return b, fmt.Errorf("invalid build %q, expected major.minor.patch.build", s)
+    }
+    for i, p := range parts {
+        n, err := strconv.Atoi(p)
+        if err != nil {
+            return b, fmt.Errorf("invalid build %q", s)
+        }
+        b[i] = n
+    }
+    return b, nil
+}
+
+func compareBuild(a, b [4]int) int {
+    for i := range a {
+        if a[i] != b[i] {
+            if a[i] < b[i] {
+                return -1
+            }
+            return 1
+        }
+    }
+    return 0
+}
+
+// DBDToMeta converts a definition into a meta for the given client file.
+// Non-inline columns are not part of the record and are skipped.
+func DBDToMeta(d *DBDFile, def *DBDDefinition, file, build string) (*MetaFile, error) {
+    version, err := parseBuild(build)
+    if err != nil {
+        return nil, err
+    }
+
+    meta := &MetaFile{File: file}
+    if strings.EqualFold(filepath.Ext(file), ".db2") {
+        meta.Format = FormatWDB2
+    }
+
+    for _, e := range def.Entries {
+        if e.HasAnnotation("noninline") {
+            continue
+        }
+        col := d.Columns[e.Name]
+        field := FieldMeta{Name: snakeCase(e.Name)}
+        if e.Count > 1 {
+            field.Count = uint32(e.Count)
+        }
+
+        switch col.Type {
+        case "int":
+            size := e.Size
+            if size == 0 {
+                size = 32
+            }
+            switch size {
+            case 8, 16, 32, 64:
+            default:
+                return nil, fmt.Errorf("column %s: unsupported int size %d", e.Name, size)
+            }
+            field.Type = fmt.Sprintf("int%d", size)
+            if e.Unsigned {
+                field.Type = "u" + field.Type
+            }
+        case "float":
+            field.Type = "float"
+        case "string":
+            field.Type = "string"
+        case "locstring":
+            // clients before Cataclysm store all locales in the record
+            field.Name = snakeCase(strings.TrimSuffix(e.Name, "_lang"))
+            field.Type = "string"
+            if version[0] < 4 {
+                field.Type = "Loc"
+            }
+        }
+
+        var notes []string
+        if !col.Verified {
+            notes = append(notes, "unverified name")
+        }
+        if e.Comment != "" {
+            notes = append(notes, e.Comment)
+        }
+        field.Note = strings.Join(notes, "; ")
+
+        // DBD foreign keys name the table and column as in the definitions; ids of 0 mean none
+        if col.ForeignTable != "" && col.Type == "int" {
+            field.Ref = &FieldRef{Table: strings.ToLower(col.ForeignTable), Field: snakeCase(col.ForeignField), None: []int64{0}}
+        }
+
+        if e.HasAnnotation("id") {
+            meta.PrimaryKeys = append(meta.PrimaryKeys, field.Name)
+            meta.SortOrder = append(meta.SortOrder, SortField{Name: field.Name, Direction: "ASC"})
+        }
+        meta.Fields = append(meta.Fields, field)
+    }
+
+    if len(meta.Fields) == 0 {
+        return nil, fmt.Errorf("definition has no inline columns")
+    }
+    if len(meta.PrimaryKeys) == 0 {
+        meta.PrimaryKeys = []string{"auto_id"}
+        meta.SortOrder = []SortField{{Name: "auto_id", Direction: "ASC"}}
+    }
+    return meta, nil
+}
+
+// snakeCase converts DBD column names like WorldStateID or UIMapID to world_state_id and ui_map_id
+func snakeCase(name string) string {
+    runes := []rune(name)
+    var b strings.Builder
+    for i, r := range runes {
+        if unicode.IsUpper(r) && i > 0 && runes[i-1] != '_' {
+            prev := runes[i-1]
+            nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+            if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+                b.WriteByte('_')
+            }
+        }
+        b.WriteRune(unicode.ToLower(r))
+    }
+    return b.String()
+}
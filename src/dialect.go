@@ -0,0 +1,478 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "database/sql"
+    "encoding/binary"
+    "fmt"
+    "hash/fnv"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// Dialect holds the SQL that differs between database backends
+type Dialect interface {
+    Name() string
+    Quote(name string) string                            // quotes an identifier
+    Rebind(query string) string                          // rewrites ? placeholders for the driver
+    Uint64(v uint64) interface{}                         // argument binding a uint64 value
+    ColumnType(typ string) (string, error)               // SQL type of a meta field type, or of "double"
+    BlobType() string                                    // SQL type of raw bytes
+    SurrogateKey() string                                // column definition of the auto_id key
+    UniqueKey(table string, i int, cols []string) string // table constraint for meta.UniqueKeys[i]
+    // Index and ForeignKey return either a clause for CREATE TABLE or a statement
+    // to run once the table (for foreign keys: every table) exists
+    Index(table string, cols []string) (clause, stmt string)
+    ForeignKey(table, col, refTable, refCol string) (clause, stmt string)
+    DeferredForeignKeys() bool                           // whether ForeignKey returns statements rather than clauses
+    Upsert(keys, cols []string) string                   // clause making INSERT update rows whose keys exist
+    MaxParams() int                                      // placeholders allowed in one statement
+    DropTable(table string) string
+    TableExists(db *sql.DB, table string) (bool, error)
+    Checksum(db *sql.DB, table string) (uint64, error) // changes whenever the table's data changes
+}
+
+// dialectFor returns the dialect of a configured driver. Plain mysql is MySQL 5.7
+// until openDB has asked the server for its version.
+func dialectFor(driver string) (Dialect, error) {
+    switch strings.ToLower(driver) {
+    case "", "mysql", "mysql57":
+        return mysql57, nil
+    case "mysql8":
+        return mysql8, nil
+    case "mariadb":
+        return mariadb, nil
+    case "sqlite":
+        return sqliteDialect{}, nil
+    case "postgres", "postgresql":
+        return postgresDialect{}, nil
+    }
+    return nil, fmt.Errorf("unknown database driver %q, expected mysql, mysql57, mysql8, mariadb, sqlite or postgres", driver)
+}
+
+// detectMySQLDialect picks the MySQL flavour from the server version
+func detectMySQLDialect(db *sql.DB) (Dialect, error) {
+    var version string
+    if err := db.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+        return nil, err
+    }
+    if strings.Contains(strings.ToLower(version), "mariadb") {
+        return mariadb, nil
+    }
+    var major, minor, patch int
+    fmt.Sscanf(version, "%d.%d.%d", &major, &minor, &patch)
+    // row aliases in upserts arrived in 8.0.19; VALUES() is deprecated from 8.0.20
+    if major > 8 || (major == 8 && (minor > 0 || patch >= 19)) {
+        return mysql8, nil
+    }
+    return mysql57, nil
+}
+
+// keyName joins the parts of an index or constraint name. Names longer than the
+// 63 bytes PostgreSQL keeps are shortened with a hash so they stay unique.
+func keyName(parts ...string) string {
+    name := strings.Join(parts, "_")
+    if len(name) <= 63 {
+        return name
+    }
+    h := fnv.New32a()
+    h.Write([]byte(name))
+    return fmt.Sprintf("%s_%08x", name[:54], h.Sum32())
+}
+
+// foreignKeyName names the constraint of a referencing column
+func foreignKeyName(table, col string) string {
+    return keyName("fk", table, col)
+}
+
+// quoteList quotes each name
+func quoteList(d Dialect, names []string) []string {
+    quoted := make([]string, len(names))
+    for i, name := range names {
+        quoted[i] = d.Quote(name)
+    }
+    return quoted
+}
+
+// mysqlDialect is MySQL and MariaDB
+type mysqlDialect struct {
+    name     string
+    rowAlias bool // upserts refer to the new row by alias instead of VALUES()
+}
+
+var (
+    mysql57 = mysqlDialect{name: "mysql57"}
+    mysql8  = mysqlDialect{name: "mysql8", rowAlias: true}
+    mariadb = mysqlDialect{name: "mariadb"}
+)
+
+func (d mysqlDialect) Name() string { return d.name }
+
+func (mysqlDialect) Quote(name string) string {
+    return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func (mysqlDialect) Rebind(query string) string { return query }
+
+func (mysqlDialect) Uint64(v uint64) interface{} { return v }
+
+func (mysqlDialect) ColumnType(typ string) (string, error) {
+    switch typ {
+    case "int8":
+        return "TINYINT", nil
+    case "int16":
+        return "SMALLINT", nil
+    case "uint16":
+        return "SMALLINT UNSIGNED", nil
+    case "int64":
+        return "BIGINT", nil
+    case "uint64":
+        return "BIGINT UNSIGNED", nil
+    case "int32":
+        return "INT", nil
+    case "uint32":
+        return "INT UNSIGNED", nil
+    case "uint8":
+        return "TINYINT UNSIGNED", nil
+    case "float":
+        return "DECIMAL(38,16)", nil
+    case "double":
+        return "DOUBLE", nil
+    case "string":
+        return "TEXT", nil
+    }
+    return "", fmt.Errorf("unknown field type: %s", typ)
+}
+
+func (mysqlDialect) BlobType() string { return "LONGBLOB" }
+
+func (mysqlDialect) SurrogateKey() string {
+    return "`auto_id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT"
+}
+
+func (d mysqlDialect) UniqueKey(table string, i int, cols []string) string {
+    return fmt.Sprintf("UNIQUE KEY `uk_%d` (%s)", i, strings.Join(quoteList(d, cols), ", "))
+}
+
+// Index names only need to be unique within the table
+func (d mysqlDialect) Index(table string, cols []string) (string, string) {
+    return fmt.Sprintf("KEY %s (%s)", d.Quote(keyName(append([]string{"idx"}, cols...)...)), strings.Join(quoteList(d, cols), ", ")), ""
+}
+
+// ForeignKey is declared inline; the connection runs with foreign_key_checks=0,
+// so the referenced table need not exist yet
+func (d mysqlDialect) ForeignKey(table, col, refTable, refCol string) (string, string) {
+    return fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+        d.Quote(foreignKeyName(table, col)), d.Quote(col), d.Quote(refTable), d.Quote(refCol)), ""
+}
+
+func (mysqlDialect) DeferredForeignKeys() bool { return false }
+
+func (d mysqlDialect) Upsert(keys, cols []string) string {
+    assignments := make([]string, len(cols))
+    for i, col := range cols {
+        if d.rowAlias {
+            assignments[i] = fmt.Sprintf("%s=new.%s", col, col)
+        } else {
+            assignments[i] = fmt.Sprintf("%s=VALUES(%s)", col, col)
+        }
+    }
+    clause := "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+    if d.rowAlias {
+        clause = "AS new " + clause
+    }
+    return clause
+}
+
+// MaxParams stays below the 65535 placeholder limit
+func (mysqlDialect) MaxParams() int { return 60000 }
+
+func (d mysqlDialect) DropTable(table string) string {
+    return "DROP TABLE IF EXISTS " + d.Quote(table)
+}
+
+func (mysqlDialect) TableExists(db *sql.DB, table string) (bool, error) {
+    var exists string
+    err := db.QueryRow("SELECT TABLE_NAME FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", table).Scan(&exists)
+    if err == sql.ErrNoRows {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+// Checksum returns the CHECKSUM TABLE value
+func (d mysqlDialect) Checksum(db *sql.DB, table string) (uint64, error) {
+    var tbl string
+    var checksum sql.NullInt64
+    err := db.QueryRow("CHECKSUM TABLE " + d.Quote(table)).Scan(&tbl, &checksum)
+    if err != nil {
+        return 0, err
+    }
+    if !checksum.Valid {
+        return 0, nil
+    }
+    return uint64(checksum.Int64), nil
+}
+
+// sqliteDialect is SQLite through the pure Go modernc.org/sqlite driver
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return "sqlite" }
+
+func (sqliteDialect) Quote(name string) string {
+    return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (sqliteDialect) Rebind(query string) string { return query }
+
+func (sqliteDialect) Uint64(v uint64) interface{} { return v }
+
+// ColumnType maps every integer to INTEGER and floats to REAL, which holds a float exactly
+func (sqliteDialect) ColumnType(typ string) (string, error) {
+    switch typ {
+    case "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64":
+        return "INTEGER", nil
+    case "float", "double":
+        return "REAL", nil
+    case "string":
+        return "TEXT", nil
+    }
+    return "", fmt.Errorf("unknown field type: %s", typ)
+}
+
+func (sqliteDialect) BlobType() string { return "BLOB" }
+
+// SurrogateKey declares auto_id as INTEGER so PRIMARY KEY(auto_id) makes it the rowid
+func (sqliteDialect) SurrogateKey() string {
+    return `"auto_id" INTEGER NOT NULL`
+}
+
+func (d sqliteDialect) UniqueKey(table string, i int, cols []string) string {
+    return fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", d.Quote(fmt.Sprintf("uk_%d", i)), strings.Join(quoteList(d, cols), ", "))
+}
+
+// Index names share one namespace per database
+func (d sqliteDialect) Index(table string, cols []string) (string, string) {
+    return "", fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+        d.Quote(keyName(append([]string{table, "idx"}, cols...)...)), d.Quote(table), strings.Join(quoteList(d, cols), ", "))
+}
+
+// ForeignKey is declared inline; SQLite only resolves the referenced table
+// when foreign key enforcement is switched on
+func (d sqliteDialect) ForeignKey(table, col, refTable, refCol string) (string, string) {
+    return fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+        d.Quote(foreignKeyName(table, col)), d.Quote(col), d.Quote(refTable), d.Quote(refCol)), ""
+}
+
+func (sqliteDialect) DeferredForeignKeys() bool { return false }
+
+func (sqliteDialect) Upsert(keys, cols []string) string {
+    assignments := make([]string, len(cols))
+    for i, col := range cols {
+        assignments[i] = fmt.Sprintf("%s=excluded.%s", col, col)
+    }
+    return "ON CONFLICT DO UPDATE SET " + strings.Join(assignments, ", ")
+}
+
+// MaxParams stays below SQLITE_MAX_VARIABLE_NUMBER (32766)
+func (sqliteDialect) MaxParams() int { return 32000 }
+
+func (d sqliteDialect) DropTable(table string) string {
+    return "DROP TABLE IF EXISTS " + d.Quote(table)
+}
+
+func (sqliteDialect) TableExists(db *sql.DB, table string) (bool, error) {
+    var exists string
+    err := db.QueryRow("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&exists)
+    if err == sql.ErrNoRows {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+func (d sqliteDialect) Checksum(db *sql.DB, table string) (uint64, error) {
+    return rowChecksum(db, d.Quote(table))
+}
+
+// postgresDialect is PostgreSQL through lib/pq
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string { return "postgres" }
+
+func (postgresDialect) Quote(name string) string {
+    return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Rebind numbers the ? placeholders outside quotes as $1, $2, ...
+// Uint64 binds a uint64 as a decimal string: database/sql rejects uint64 arguments
+// of 2^63 and above, which NUMERIC(20) columns hold
+func (postgresDialect) Uint64(v uint64) interface{} { return strconv.FormatUint(v, 10) }
+
+func (postgresDialect) Rebind(query string) string {
+    var b strings.Builder
+    var quote byte
+    n := 0
+    for i := 0; i < len(query); i++ {
+        ch := query[i]
+        switch {
+        case quote != 0:
+            if ch == quote {
+                quote = 0
+            }
+        case ch == '\'' || ch == '"':
+            quote = ch
+        case ch == '?':
+            n++
+            fmt.Fprintf(&b, "$%d", n)
+            continue
+        }
+        b.WriteByte(ch)
+    }
+    return b.String()
+}
+
+// ColumnType picks the smallest signed type that holds every value; there are no unsigned types.
+// Floats are stored as DOUBLE PRECISION, which holds a float exactly, including -0.
+func (postgresDialect) ColumnType(typ string) (string, error) {
+    switch typ {
+    case "int8", "uint8", "int16":
+        return "SMALLINT", nil
+    case "uint16", "int32":
+        return "INTEGER", nil
+    case "uint32", "int64":
+        return "BIGINT", nil
+    case "uint64":
+        return "NUMERIC(20)", nil
+    case "float", "double":
+        return "DOUBLE PRECISION", nil
+    case "string":
+        return "TEXT", nil
+    }
+    return "", fmt.Errorf("unknown field type: %s", typ)
+}
+
+func (postgresDialect) BlobType() string { return "BYTEA" }
+
+func (postgresDialect) SurrogateKey() string {
+    return `"auto_id" BIGSERIAL NOT NULL`
+}
+
+// UniqueKey prefixes the constraint with the table name, since constraint
+// names share one namespace per schema
+func (d postgresDialect) UniqueKey(table string, i int, cols []string) string {
+    return fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", d.Quote(fmt.Sprintf("%s_uk_%d", table, i)), strings.Join(quoteList(d, cols), ", "))
+}
+
+func (d postgresDialect) Index(table string, cols []string) (string, string) {
+    return "", fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+        d.Quote(keyName(append([]string{table, "idx"}, cols...)...)), d.Quote(table), strings.Join(quoteList(d, cols), ", "))
+}
+
+// ForeignKey is added once every table exists. NOT VALID skips checking the rows
+// already loaded, so ids that dangle in the DBCs do not fail the import.
+func (d postgresDialect) ForeignKey(table, col, refTable, refCol string) (string, string) {
+    return "", fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) NOT VALID",
+        d.Quote(table), d.Quote(foreignKeyName(table, col)), d.Quote(col), d.Quote(refTable), d.Quote(refCol))
+}
+
+func (postgresDialect) DeferredForeignKeys() bool { return true }
+
+// Upsert needs the conflicting key; without one, rows are only inserted
+func (postgresDialect) Upsert(keys, cols []string) string {
+    if len(keys) == 0 {
+        return "ON CONFLICT DO NOTHING"
+    }
+    assignments := make([]string, len(cols))
+    for i, col := range cols {
+        assignments[i] = fmt.Sprintf("%s=EXCLUDED.%s", col, col)
+    }
+    return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ", "), strings.Join(assignments, ", "))
+}
+
+// MaxParams stays below the 65535 parameter limit
+func (postgresDialect) MaxParams() int { return 60000 }
+
+// DropTable also drops the foreign keys of other tables referencing this one;
+// import adds them back once the table is loaded again
+func (d postgresDialect) DropTable(table string) string {
+    return "DROP TABLE IF EXISTS " + d.Quote(table) + " CASCADE"
+}
+
+func (postgresDialect) TableExists(db *sql.DB, table string) (bool, error) {
+    var exists string
+    err := db.QueryRow("SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1", table).Scan(&exists)
+    if err == sql.ErrNoRows {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+func (d postgresDialect) Checksum(db *sql.DB, table string) (uint64, error) {
+    return rowChecksum(db, d.Quote(table))
+}
+
+// rowChecksum hashes every row of a table for backends without CHECKSUM TABLE.
+// Row hashes are summed, so like CHECKSUM TABLE the result does not depend on
+// row order. It is kept below 2^63 to fit a signed BIGINT.
+func rowChecksum(db *sql.DB, quotedTable string) (uint64, error) {
+    rows, err := db.Query("SELECT * FROM " + quotedTable)
+    if err != nil {
+        return 0, err
+    }
+    defer rows.Close()
+
+    cols, err := rows.Columns()
+    if err != nil {
+        return 0, err
+    }
+    raw := make([]interface{}, len(cols))
+    ptrs := make([]interface{}, len(cols))
+    for i := range raw {
+        ptrs[i] = &raw[i]
+    }
+
+    var sum uint64
+    var buf [8]byte
+    for rows.Next() {
+        if err := rows.Scan(ptrs...); err != nil {
+            return 0, err
+        }
+        h := fnv.New64a()
+        for _, v := range raw {
+            // a type tag and length prefix keep adjacent values from running together
+            switch v := v.(type) {
+            case nil:
+                h.Write([]byte{0})
+            case int64:
+                binary.LittleEndian.PutUint64(buf[:], uint64(v))
+                h.Write([]byte{1})
+                h.Write(buf[:])
+            case float64:
+                binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+                h.Write([]byte{2})
+                h.Write(buf[:])
+            case []byte:
+                binary.LittleEndian.PutUint64(buf[:], uint64(len(v)))
+                h.Write([]byte{3})
+                h.Write(buf[:])
+                h.Write(v)
+            default:
+                s := fmt.Sprint(v)
+                binary.LittleEndian.PutUint64(buf[:], uint64(len(s)))
+                h.Write([]byte{3})
+                h.Write(buf[:])
+                h.Write([]byte(s))
+            }
+        }
+        sum += h.Sum64()
+    }
+    if err := rows.Err(); err != nil {
+        return 0, err
+    }
+    return sum &^ (1 << 63), nil
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// Kinds of record differences
+const (
+    DiffAdded   = "added"
+    DiffRemoved = "removed"
+    DiffChanged = "changed"
+)
+
+// FieldChange is a single differing value, formatted for display
+type FieldChange struct {
+    Field string `json:"field"`
+    Old   string `json:"old"`
+    New   string `json:"new"`
+}
+
+// RecordDiff describes one record that differs between two tables
+type RecordDiff struct {
+    Key     string        `json:"key"`
+    Kind    string        `json:"kind"`
+    Row     int           `json:"row"` // row in the left table, or the right one for added records
+    Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// TableDiff is the structural difference between two parsed DBC files
+type TableDiff struct {
+    Header    []FieldChange `json:"header,omitempty"`
+    Records   []RecordDiff  `json:"records,omitempty"`
+    Reordered bool          `json:"reordered,omitempty"` // same records in a different order
+    Added     int           `json:"added"`
+    Removed   int           `json:"removed"`
+    Changed   int           `json:"changed"`
+}
+
+// Empty reports whether no differences were found
+func (d *TableDiff) Empty() bool {
+    return len(d.Header) == 0 && len(d.Records) == 0 && !d.Reordered
+}
+
+// DiffOptions tunes what counts as a difference
+type DiffOptions struct {
+    FloatTolerance float64         // floats closer than this are equal
+    Ignore         map[string]bool // column, field or header field names to skip; a field name covers all its columns
+}
+
+// ignored reports whether a column is excluded by the options
+func (o DiffOptions) ignored(schema *Schema, c int) bool {
+    col := schema.Columns[c]
+    return o.Ignore[col.Name] || o.Ignore[schema.Meta.Fields[col.Field].Name]
+}
+
+// DiffDBC compares two files parsed with the same meta. Records are matched by
+// primary key, or by position if the meta has no usable key, and string fields
+// are compared by their text rather than their offsets.
+func DiffDBC(left, right *DBCFile, opts DiffOptions) (*TableDiff, error) {
+    ls, rs := left.Table.Schema, right.Table.Schema
+    if len(ls.Columns) != len(rs.Columns) {
+        return nil, fmt.Errorf("column count differs: %d vs %d", len(ls.Columns), len(rs.Columns))
+    }
+
+    diff := &TableDiff{Header: diffHeader(left.Header, right.Header, opts)}
+
+    keyCols := keyColumns(ls)
+    leftKeys := recordKeys(left, keyCols)
+    rightKeys := recordKeys(right, keyCols)
+
+    rightRows := make(map[string]int, len(rightKeys))
+    for row, key := range rightKeys {
+        rightRows[key] = row
+    }
+    leftRows := make(map[string]int, len(leftKeys))
+    for row, key := range leftKeys {
+        leftRows[key] = row
+    }
+
+    // matched records, in left order, for the reorder check
+    var matched []int
+    for row, key := range leftKeys {
+        other, ok := rightRows[key]
+        if !ok {
+            diff.Records = append(diff.Records, RecordDiff{Key: key, Kind: DiffRemoved, Row: row})
+            diff.Removed++
+            continue
+        }
+        matched = append(matched, other)
+        if changes := diffRecord(left, row, right, other, opts); len(changes) > 0 {
+            diff.Records = append(diff.Records, RecordDiff{Key: key, Kind: DiffChanged, Row: row, Changes: changes})
+            diff.Changed++
+        }
+    }
+    for row, key := range rightKeys {
+        if _, ok := leftRows[key]; !ok {
+            diff.Records = append(diff.Records, RecordDiff{Key: key, Kind: DiffAdded, Row: row})
+            diff.Added++
+        }
+    }
+
+    for i := 1; i < len(matched); i++ {
+        if matched[i] < matched[i-1] {
+            diff.Reordered = true
+            break
+        }
+    }
+
+    return diff, nil
+}
+
+// keyColumns returns the primary key columns of a schema, or nil to match by position
+func keyColumns(schema *Schema) []int {
+    var cols []int
+    for _, name := range schema.Meta.PrimaryKeys {
+        if c, ok := schema.Lookup(name); ok {
+            cols = append(cols, c)
+        }
+    }
+    return cols
+}
+
+// recordKeys builds the match key of every row. Repeated keys get an occurrence
+// suffix so duplicates are paired up in order.
+func recordKeys(dbc *DBCFile, keyCols []int) []string {
+    keys := make([]string, dbc.Table.Len())
+    seen := map[string]int{}
+    for row := range keys {
+        var key string
+        if len(keyCols) == 0 {
+            key = fmt.Sprintf("#%d", row)
+        } else {
+            parts := make([]string, len(keyCols))
+            for i, c := range keyCols {
+                parts[i] = formatValue(dbc, row, c)
+            }
+            key = strings.Join(parts, ":")
+        }
+        if n := seen[key]; n > 0 {
+            seen[key] = n + 1
+            key = fmt.Sprintf("%s (%d)", key, n+1)
+        } else {
+            seen[key] = 1
+        }
+        keys[row] = key
+    }
+    return keys
+}
+
+// diffRecord compares two rows column by column
+func diffRecord(left *DBCFile, lrow int, right *DBCFile, rrow int, opts DiffOptions) []FieldChange {
+    var changes []FieldChange
+    schema := left.Table.Schema
+    for c, col := range schema.Columns {
+        if opts.ignored(schema, c) {
+            continue
+        }
+        var equal bool
+        switch {
+        case col.Type == "string":
+            equal = left.Text(lrow, c) == right.Text(rrow, c)
+        case col.Type == "float" && opts.FloatTolerance > 0:
+            // identical bits are equal even for NaN, which never compares equal as a number
+            a, b := float64(left.Table.Float(lrow, c)), float64(right.Table.Float(rrow, c))
+            equal = left.Table.Raw(lrow, c) == right.Table.Raw(rrow, c) || math.Abs(a-b) <= opts.FloatTolerance
+        default:
+            equal = left.Table.Raw(lrow, c) == right.Table.Raw(rrow, c)
+        }
+        if !equal {
+            changes = append(changes, FieldChange{
+                Field: col.Name,
+                Old:   formatValue(left, lrow, c),
+                New:   formatValue(right, rrow, c),
+            })
+        }
+    }
+    return changes
+}
+
+// diffHeader lists header fields that differ and are not ignored
+func diffHeader(a, b DBCHeader, opts DiffOptions) []FieldChange {
+    var changes []FieldChange
+    add := func(name string, x, y interface{}) {
+        if x != y && !opts.Ignore[name] {
+            changes = append(changes, FieldChange{Field: name, Old: fmt.Sprint(x), New: fmt.Sprint(y)})
+        }
+    }
+    add("magic", string(a.Magic[:]), string(b.Magic[:]))
+    add("record_count", a.RecordCount, b.RecordCount)
+    add("field_count", a.FieldCount, b.FieldCount)
+    add("record_size", a.RecordSize, b.RecordSize)
+    add("string_block_size", a.StringBlockSize, b.StringBlockSize)
+    if a.Format() == FormatWDB2 || b.Format() == FormatWDB2 {
+        add("table_hash", a.TableHash, b.TableHash)
+        add("build", a.Build, b.Build)
+        add("timestamp", a.Timestamp, b.Timestamp)
+        add("min_id", a.MinID, b.MinID)
+        add("max_id", a.MaxID, b.MaxID)
+        add("locale", a.Locale, b.Locale)
+        add("copy_table_size", a.CopyTableSize, b.CopyTableSize)
+    }
+    return changes
+}
+
+// formatValue renders a value for diff output; strings are quoted text
+func formatValue(dbc *DBCFile, row, col int) string {
+    switch dbc.Table.Schema.Columns[col].Type {
+    case "string":
+        return strconv.Quote(dbc.Text(row, col))
+    case "float":
+        return strconv.FormatFloat(float64(dbc.Table.Float(row, col)), 'g', -1, 32)
+    default:
+        return fmt.Sprint(dbc.Table.Value(row, col))
+    }
+}
+
+// PrintDiff writes a human readable report of a diff
+func PrintDiff(w io.Writer, d *TableDiff) {
+    for _, h := range d.Header {
+        fmt.Fprintf(w, "  header %s: %s -> %s\n", h.Field, h.Old, h.New)
+    }
+    for _, r := range d.Records {
+        switch r.Kind {
+        case DiffAdded:
+            fmt.Fprintf(w, "  + record %s added\n", r.Key)
+        case DiffRemoved:
+            fmt.Fprintf(w, "  - record %s removed\n", r.Key)
+        case DiffChanged:
+            fmt.Fprintf(w, "  ~ record %s changed\n", r.Key)
+            for _, c := range r.Changes {
+                fmt.Fprintf(w, "      %s: %s -> %s\n", c.Field, c.Old, c.New)
+            }
+        }
+    }
+    if d.Reordered {
+        fmt.Fprintln(w, "  records are in a different order")
+    }
+    fmt.Fprintf(w, "  %d added, %d removed, %d changed\n", d.Added, d.Removed, d.Changed)
+}
+
+// PrintUnifiedDiff writes a diff in the style of diff -u, one hunk per record
+func PrintUnifiedDiff(w io.Writer, left, right *DBCFile, leftName, rightName string, d *TableDiff) {
+    fmt.Fprintf(w, "--- %s\n+++ %s\n", leftName, rightName)
+    for _, h := range d.Header {
+        fmt.Fprintf(w, "@@ header @@\n-%s: %s\n+%s: %s\n", h.Field, h.Old, h.Field, h.New)
+    }
+    for _, r := range d.Records {
+        fmt.Fprintf(w, "@@ %s @@\n", r.Key)
+        switch r.Kind {
+        case DiffRemoved:
+            for c, col := range left.Table.Schema.Columns {
+                fmt.Fprintf(w, "-%s: %s\n", col.Name, formatValue(left, r.Row, c))
+            }
+        case DiffAdded:
+            for c, col := range right.Table.Schema.Columns {
+                fmt.Fprintf(w, "+%s: %s\n", col.Name, formatValue(right, r.Row, c))
+            }
+        case DiffChanged:
+            for _, c := range r.Changes {
+                fmt.Fprintf(w, "-%s: %s\n+%s: %s\n", c.Field, c.Old, c.Field, c.New)
+            }
+        }
+    }
+}
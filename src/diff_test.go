@@ -0,0 +1,45 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "math"
+    "testing"
+)
+
+func TestDiffRecordFloatTolerance(t *testing.T) {
+    meta := &MetaFile{File: "Test.dbc", PrimaryKeys: []string{"id"}, Fields: []FieldMeta{
+        {Name: "id", Type: "uint32"},
+        {Name: "radius", Type: "float"},
+    }}
+    schema, err := NewSchema(meta)
+    if err != nil {
+        t.Fatal(err)
+    }
+    single := func(bits uint32) *DBCFile {
+        dbc := &DBCFile{Table: NewTable(schema, 1), StringBlock: []byte{0}}
+        dbc.Table.SetRaw(dbc.Table.AppendRow(), 1, uint64(bits))
+        return dbc
+    }
+    nan := math.Float32bits(float32(math.NaN()))
+
+    tests := []struct {
+        a, b    uint32
+        changed bool
+    }{
+        {nan, nan, false},
+        {nan, math.Float32bits(0), true},
+        {math.Float32bits(1), math.Float32bits(1.0005), false},
+        {math.Float32bits(1), math.Float32bits(1.01), true},
+        {math.Float32bits(0), math.Float32bits(float32(math.Copysign(0, -1))), false},
+    }
+    for _, tt := range tests {
+        changes := diffRecord(single(tt.a), 0, single(tt.b), 0, DiffOptions{FloatTolerance: 0.001})
+        if changed := len(changes) > 0; changed != tt.changed {
+            t.Errorf("%08X vs %08X: changed = %v, want %v", tt.a, tt.b, changed, tt.changed)
+        }
+    }
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// Edit is a set of column assignments for the record with the given key
+type Edit struct {
+    Key  string
+    Sets []string // column=value
+}
+
+// EditChange is one applied assignment, for reporting
+type EditChange struct {
+    Key    string
+    Column string
+    Old    string
+    New    string
+}
+
+// ApplyEdits applies assignments to records matched by primary key. Composite keys
+// are joined with ':', tables without a primary key are addressed by position as
+// #<row>. Edits are applied in order, so a record whose key was changed is addressed
+// by its new key afterwards, and a change to a key already in use is rejected.
+// New strings are appended to the string block so existing offsets stay valid.
+func ApplyEdits(dbc *DBCFile, edits []Edit) ([]EditChange, error) {
+    table := dbc.Table
+    schema := table.Schema
+    keyCols := keyColumns(schema)
+    isKey := map[int]bool{}
+    for _, c := range keyCols {
+        isKey[c] = true
+    }
+
+    rows := map[string][]int{}
+    for row := 0; row < table.Len(); row++ {
+        key := editKey(dbc, row, keyCols)
+        rows[key] = append(rows[key], row)
+    }
+
+    prev := *dbc
+    strs := &stringTable{block: dbc.StringBlock, offsets: map[string]uint32{}}
+    edited := map[int]bool{}
+    var changes []EditChange
+
+    for _, e := range edits {
+        matches := rows[e.Key]
+        if len(matches) == 0 {
+            return nil, fmt.Errorf("no record with key %s", e.Key)
+        }
+        if len(matches) > 1 {
+            return nil, fmt.Errorf("key %s matches %d records", e.Key, len(matches))
+        }
+        row := matches[0]
+        rekeyed := false
+
+        for _, set := range e.Sets {
+            name, text, ok := strings.Cut(set, "=")
+            if !ok {
+                return nil, fmt.Errorf("key %s: expected column=value, got %q", e.Key, set)
+            }
+            name = strings.TrimSpace(name)
+            c, ok := schema.Lookup(name)
+            if !ok {
+                return nil, fmt.Errorf("key %s: unknown column %s", e.Key, name)
+            }
+            col := schema.Columns[c]
+
+            old := formatValue(dbc, row, c)
+            var bits uint64
+            if col.Type == "string" {
+                bits = uint64(strs.offset(text))
+                dbc.StringBlock = strs.block
+            } else {
+                if text == "" {
+                    return nil, fmt.Errorf("key %s, column %s: missing value", e.Key, name)
+                }
+                var err error
+                if bits, err = parseCell(col, text); err != nil {
+                    return nil, fmt.Errorf("key %s, column %s: %w", e.Key, name, err)
+                }
+            }
+            table.SetRaw(row, c, bits)
+            edited[row] = true
+            rekeyed = rekeyed || isKey[c]
+            if c == 0 {
+                // a changed WDB2 id invalidates the id index, so it is rebuilt
+                prev.IndexTable = nil
+            }
+            changes = append(changes, EditChange{Key: e.Key, Column: name, Old: old, New: formatValue(dbc, row, c)})
+        }
+
+        if rekeyed {
+            if key := editKey(dbc, row, keyCols); key != e.Key {
+                if len(rows[key]) > 0 {
+                    return nil, fmt.Errorf("key %s: a record with key %s already exists", e.Key, key)
+                }
+                delete(rows, e.Key)
+                rows[key] = []int{row}
+            }
+        }
+    }
+
+    if err := FinishHeader(dbc, &prev); err != nil {
+        return nil, err
+    }
+
+    // reused WDB2 string lengths still describe the old text of edited records
+    if len(dbc.StringLengths) > 0 {
+        for row := range edited {
+            id := uint32(table.Raw(row, 0))
+            dbc.StringLengths[id-dbc.Header.MinID] = clampUint16(rowStringLength(dbc, row))
+        }
+    }
+    return changes, nil
+}
+
+// editKey is the key ApplyEdits addresses a record by
+func editKey(dbc *DBCFile, row int, keyCols []int) string {
+    if len(keyCols) == 0 {
+        return fmt.Sprintf("#%d", row)
+    }
+    parts := make([]string, len(keyCols))
+    for i, c := range keyCols {
+        parts[i] = formatCell(dbc, row, c)
+    }
+    return strings.Join(parts, ":")
+}
+
+// ReadEdits reads batch edits, one record per line: the key followed by column=value
+// pairs. Values containing spaces are written as double-quoted Go strings, and
+// blank lines and lines starting with # are ignored.
+func ReadEdits(r io.Reader) ([]Edit, error) {
+    var edits []Edit
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    line := 0
+    for scanner.Scan() {
+        line++
+        text := strings.TrimSpace(scanner.Text())
+        if text == "" || strings.HasPrefix(text, "#") {
+            continue
+        }
+        fields, err := splitEditLine(text)
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %w", line, err)
+        }
+        if len(fields) < 2 {
+            return nil, fmt.Errorf("line %d: expected a key and at least one column=value", line)
+        }
+        edits = append(edits, Edit{Key: fields[0], Sets: fields[1:]})
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return edits, nil
+}
+
+// splitEditLine splits a line at whitespace outside double-quoted strings and
+// unquotes them, so name_enus="Fire Ball" is a single field
+func splitEditLine(line string) ([]string, error) {
+    var fields []string
+    var b strings.Builder
+    inField := false
+    for i := 0; i < len(line); {
+        ch := line[i]
+        switch {
+        case ch == '"':
+            quoted, err := strconv.QuotedPrefix(line[i:])
+            if err != nil {
+                return nil, fmt.Errorf("bad quoted string at column %d", i+1)
+            }
+            s, _ := strconv.Unquote(quoted)
+            b.WriteString(s)
+            inField = true
+            i += len(quoted)
+        case unicode.IsSpace(rune(ch)):
+            if inField {
+                fields = append(fields, b.String())
+                b.Reset()
+                inField = false
+            }
+            i++
+        default:
+            b.WriteByte(ch)
+            inField = true
+            i++
+        }
+    }
+    if inField {
+        fields = append(fields, b.String())
+    }
+    return fields, nil
+}
@@ -5,7 +5,31 @@ go 1.22.0
 require(
     github.com/go-sql-driver/mysql v1.9.3
     filippo.io/edwards25519 v1.1.0
+    github.com/lib/pq v1.9.0
+    modernc.org/sqlite v1.36.1
+    modernc.org/libc v1.61.13
+    modernc.org/mathutil v1.7.1
+    modernc.org/memory v1.8.2
+    github.com/dustin/go-humanize v1.0.1
+    github.com/google/uuid v1.6.0
+    github.com/mattn/go-isatty v0.0.20
+    github.com/ncruces/go-strftime v0.1.9
+    github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec
+    golang.org/x/exp v0.0.0-20230315142452-642cacee5cc0
+    golang.org/x/sys v0.30.0
 )
 
 replace github.com/go-sql-driver/mysql => ../dep/mysql
-replace filippo.io/edwards25519 => ../dep/edwards25519
\ No newline at end of file
+replace filippo.io/edwards25519 => ../dep/edwards25519
+replace github.com/lib/pq => ../dep/pq
+replace modernc.org/sqlite => ../dep/sqlite
+replace modernc.org/libc => ../dep/libc
+replace modernc.org/mathutil => ../dep/mathutil
+replace modernc.org/memory => ../dep/memory
+replace github.com/dustin/go-humanize => ../dep/go-humanize
+replace github.com/google/uuid => ../dep/uuid
+replace github.com/mattn/go-isatty => ../dep/go-isatty
+replace github.com/ncruces/go-strftime => ../dep/go-strftime
+replace github.com/remyoudompheng/bigfft => ../dep/bigfft
+replace golang.org/x/exp => ../dep/exp
+replace golang.org/x/sys => ../dep/sys
\ No newline at end of file
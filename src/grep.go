@@ -0,0 +1,200 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "fmt"
+    "log"
+    "math"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// GrepOptions selects what a value search matches
+type GrepOptions struct {
+    Value     string
+    Type      string // scalar column type to search, or "" for every type the value parses as
+    Substring bool   // match strings containing the value instead of equal to it
+}
+
+// GrepMatch is one field holding the searched value
+type GrepMatch struct {
+    File   string
+    Key    string
+    Column string
+    Text   string
+}
+
+// grepMatcher tests the cells of one column type
+type grepMatcher func(dbc *DBCFile, row, c int) bool
+
+// GrepDBCs searches every DBC with a meta for a value, loading files in parallel.
+// Matches are returned in meta, record and column order.
+func GrepDBCs(cfg *Config, opts GrepOptions) ([]GrepMatch, error) {
+    matchers, err := grepMatchers(opts)
+    if err != nil {
+        return nil, err
+    }
+
+    metas, err := filepath.Glob(filepath.Join(cfg.Paths.Meta, "*.meta.json"))
+    if err != nil {
+        return nil, fmt.Errorf("failed to scan meta directory: %w", err)
+    }
+
+    // open the archives before the workers share them
+    if len(cfg.Paths.MPQ) > 0 {
+        if _, err := cfg.archives(); err != nil {
+            return nil, fmt.Errorf("failed to open MPQ archives: %w", err)
+        }
+    }
+
+    results := make([][]GrepMatch, len(metas))
+    jobs := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < runtime.NumCPU(); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range jobs {
+                results[i] = grepMeta(cfg, metas[i], matchers)
+            }
+        }()
+    }
+    for i := range metas {
+        jobs <- i
+    }
+    close(jobs)
+    wg.Wait()
+
+    var matches []GrepMatch
+    for _, r := range results {
+        matches = append(matches, r...)
+    }
+    return matches, nil
+}
+
+// grepMeta searches the DBC of one meta. Files that cannot be loaded are skipped.
+func grepMeta(cfg *Config, metaPath string, matchers map[string]grepMatcher) []GrepMatch {
+    meta, err := LoadMeta(metaPath)
+    if err != nil {
+        log.Printf("Skipping %s: %v", metaPath, err)
+        return nil
+    }
+    if !dbcExists(cfg, meta.File) {
+        return nil
+    }
+    dbc, err := LoadDBCFile(cfg, meta)
+    if err != nil {
+        log.Printf("Skipping %s: %v", meta.File, err)
+        return nil
+    }
+
+    schema := dbc.Table.Schema
+    var cols []int
+    for c, col := range schema.Columns {
+        if matchers[col.Type] != nil {
+            cols = append(cols, c)
+        }
+    }
+    if len(cols) == 0 {
+        return nil
+    }
+
+    keyCols := keyColumns(schema)
+    var matches []GrepMatch
+    for row := 0; row < dbc.Table.Len(); row++ {
+        for _, c := range cols {
+            col := schema.Columns[c]
+            if !matchers[col.Type](&dbc, row, c) {
+                continue
+            }
+            matches = append(matches, GrepMatch{
+                File:   meta.File,
+                Key:    recordLabel(&dbc, row, keyCols),
+                Column: col.Name,
+                Text:   formatValue(&dbc, row, c),
+            })
+        }
+    }
+    return matches
+}
+
+// recordLabel names a record by its primary key, or by position without one
+func recordLabel(dbc *DBCFile, row int, keyCols []int) string {
+    if len(keyCols) == 0 {
+        return fmt.Sprintf("#%d", row)
+    }
+    parts := make([]string, len(keyCols))
+    for i, c := range keyCols {
+        parts[i] = dbc.Table.Schema.Columns[c].Name + "=" + formatCell(dbc, row, c)
+    }
+    return strings.Join(parts, ",")
+}
+
+// grepMatchers builds a matcher for each column type the value is searched in.
+// Numbers are compared by value, so 12345 matches every integer width that can
+// hold it; strings match the text of string and Loc columns.
+func grepMatchers(opts GrepOptions) (map[string]grepMatcher, error) {
+    types := []string{"int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64", "float", "string"}
+    if opts.Type != "" {
+        valid := false
+        for _, t := range types {
+            valid = valid || t == opts.Type
+        }
+        if !valid {
+            return nil, fmt.Errorf("unknown type %q, expected one of %s", opts.Type, strings.Join(types, ", "))
+        }
+        types = []string{opts.Type}
+    }
+
+    value := opts.Value
+    matchers := map[string]grepMatcher{}
+    for _, typ := range types {
+        switch typ {
+        case "string":
+            if opts.Substring {
+                matchers[typ] = func(dbc *DBCFile, row, c int) bool {
+                    return strings.Contains(dbc.Text(row, c), value)
+                }
+            } else {
+                matchers[typ] = func(dbc *DBCFile, row, c int) bool {
+                    return dbc.Text(row, c) == value
+                }
+            }
+        case "float":
+            f, err := strconv.ParseFloat(value, 32)
+            if err != nil {
+                continue
+            }
+            bits := uint64(math.Float32bits(float32(f)))
+            matchers[typ] = func(dbc *DBCFile, row, c int) bool {
+                return dbc.Table.Raw(row, c) == bits
+            }
+        default:
+            // parseCell range checks the value against the column width
+            size, _ := fieldSize(typ)
+            bits, err := parseCell(Column{Type: typ, Size: size}, value)
+            if err != nil || value == "" {
+                continue
+            }
+            if size < 8 {
+                // stored values are zero-extended, negative ones included
+                bits &= 1<<(size*8) - 1
+            }
+            matchers[typ] = func(dbc *DBCFile, row, c int) bool {
+                return dbc.Table.Raw(row, c) == bits
+            }
+        }
+    }
+    if len(matchers) == 0 {
+        return nil, fmt.Errorf("%q is not a valid %s value", value, opts.Type)
+    }
+    return matchers, nil
+}
+
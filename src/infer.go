@@ -0,0 +1,336 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "math"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// columnStats collects what the values of one 4-byte column look like
+type columnStats struct {
+    nonZero   int
+    strings   int // values pointing at the start of a string in the string block
+    floats    int // values whose bits read as a plausible float
+    negatives int // values that are small negative numbers as int32
+    highBit   int // values with the sign bit set
+    distinct  map[uint32]struct{}
+}
+
+// column kinds assigned by classifyColumn
+const (
+    inferZero   = "zero"
+    inferString = "string"
+    inferFloat  = "float"
+    inferInt    = "int32"
+    inferUint   = "uint32"
+)
+
+// inferredColumn is the classification of one 4-byte column
+type inferredColumn struct {
+    kind string
+    note string
+}
+
+// InferMeta guesses a meta layout for a DBC from its header, records and string block.
+// Every field carries a note describing how confident the guess is.
+func InferMeta(data []byte, file string) (*MetaFile, error) {
+    header, err := ParseHeader(data)
+    if err != nil {
+        return nil, err
+    }
+
+    recordsStart := header.Size() + header.IndexEntries()*6
+    recordCount := int(header.RecordCount)
+    recordSize := int(header.RecordSize)
+    stringBlockStart := recordsStart + recordCount*recordSize
+    if stringBlockStart+int(header.StringBlockSize) > len(data) {
+        return nil, fmt.Errorf("file too small for records + string block")
+    }
+    records := data[recordsStart:stringBlockStart]
+    stringBlock := data[stringBlockStart : stringBlockStart+int(header.StringBlockSize)]
+
+    meta := &MetaFile{File: file}
+    if header.Format() == FormatWDB2 {
+        meta.Format = FormatWDB2
+    }
+
+    // Only 4-byte columns can be told apart from the data alone. Files with narrower
+    // fields get their trailing bytes as uint8 and a note to fix the layout by hand.
+    columns := recordSize / 4
+    trailing := recordSize % 4
+    layoutNote := ""
+    if int(header.FieldCount)*4 != recordSize {
+        layoutNote = fmt.Sprintf("header has %d fields in %d bytes, some fields are narrower than 4 bytes", header.FieldCount, recordSize)
+    }
+
+    inferred := make([]inferredColumn, columns)
+    for c := 0; c < columns; c++ {
+        stats := collectColumnStats(records, recordCount, recordSize, c*4, stringBlock)
+        inferred[c] = classifyColumn(stats, recordCount)
+    }
+
+    for c := 0; c < columns; {
+        if c+len(locLangs) <= columns && isLocRun(inferred[c:c+len(locLangs)]) {
+            meta.Fields = append(meta.Fields, FieldMeta{
+                Name: fmt.Sprintf("loc_%d", c),
+                Type: "Loc",
+                Note: "17-column localized string run",
+            })
+            c += len(locLangs)
+            continue
+        }
+
+        col := inferred[c]
+        field := FieldMeta{Name: fmt.Sprintf("field_%d", c), Note: col.note}
+        switch col.kind {
+        case inferString:
+            field.Type = "string"
+        case inferFloat:
+            field.Type = "float"
+        case inferInt:
+            field.Type = "int32"
+        default:
+            field.Type = "uint32"
+        }
+        if c == 0 && isUniqueID(records, recordCount, recordSize) && field.Type == "uint32" {
+            field.Name = "id"
+            field.Note = "unique non-zero values, assumed to be the record id"
+            meta.PrimaryKeys = []string{"id"}
+        }
+        meta.Fields = append(meta.Fields, field)
+        c++
+    }
+
+    for i := 0; i < trailing; i++ {
+        meta.Fields = append(meta.Fields, FieldMeta{
+            Name: fmt.Sprintf("byte_%d", columns*4+i),
+            Type: "uint8",
+            Note: "trailing byte, layout guessed",
+        })
+    }
+
+    if layoutNote != "" && len(meta.Fields) > 0 {
+        meta.Fields[0].Note = strings.TrimPrefix(meta.Fields[0].Note+"; "+layoutNote, "; ")
+    }
+    if meta.PrimaryKeys == nil {
+        meta.PrimaryKeys = []string{}
+    }
+
+    return meta, nil
+}
+
+// collectColumnStats scans one 4-byte column across all records
+func collectColumnStats(records []byte, count, recordSize, offset int, stringBlock []byte) columnStats {
+    stats := columnStats{distinct: map[uint32]struct{}{}}
+    for r := 0; r < count; r++ {
+        v := binary.LittleEndian.Uint32(records[r*recordSize+offset:])
+        if v == 0 {
+            continue
+        }
+        stats.nonZero++
+        if len(stats.distinct) < 1024 {
+            stats.distinct[v] = struct{}{}
+        }
+        if v == 0x80000000 {
+            // -0.0 shows up in float columns but tells nothing else
+            stats.floats++
+            continue
+        }
+        if isStringOffset(stringBlock, v) {
+            stats.strings++
+        }
+        if isPlausibleFloat(v) {
+            stats.floats++
+        }
+        if v&0x80000000 != 0 {
+            stats.highBit++
+            if int32(v) > -1000000 {
+                stats.negatives++
+            }
+        }
+    }
+    return stats
+}
+
+// classifyColumn picks the most likely type of a column from its stats
+func classifyColumn(s columnStats, count int) inferredColumn {
+    if s.nonZero == 0 {
+        return inferredColumn{inferZero, "always 0, type unknown"}
+    }
+
+    confidence := "high"
+    if s.nonZero < 5 || len(s.distinct) < 3 {
+        confidence = "low"
+    }
+    sample := fmt.Sprintf("%d of %d records non-zero, %d distinct", s.nonZero, count, len(s.distinct))
+
+    // offsets into the string block are the strongest signal: random ints rarely
+    // all land on the first byte after a null
+    if s.strings == s.nonZero {
+        if len(s.distinct) == 1 {
+            confidence = "low"
+        }
+        return inferredColumn{inferString, fmt.Sprintf("%s: all values are string offsets (%s)", confidence, sample)}
+    }
+
+    if s.floats == s.nonZero {
+        return inferredColumn{inferFloat, fmt.Sprintf("%s: all values read as plausible floats (%s)", confidence, sample)}
+    }
+    if s.floats*10 >= s.nonZero*9 {
+        return inferredColumn{inferFloat, fmt.Sprintf("low: %d of %d values read as plausible floats (%s)", s.floats, s.nonZero, sample)}
+    }
+
+    if s.negatives > 0 && s.negatives == s.highBit {
+        return inferredColumn{inferInt, fmt.Sprintf("%s: %d small negative values (%s)", confidence, s.negatives, sample)}
+    }
+    if s.highBit > 0 {
+        return inferredColumn{inferUint, fmt.Sprintf("%s: values with the high bit set, likely flags (%s)", confidence, sample)}
+    }
+    return inferredColumn{inferUint, fmt.Sprintf("%s: integer values (%s)", confidence, sample)}
+}
+
+// isStringOffset reports whether v points at the start of a string in the block
+func isStringOffset(stringBlock []byte, v uint32) bool {
+    if v == 0 || v >= uint32(len(stringBlock)) {
+        return false
+    }
+    return stringBlock[v-1] == 0
+}
+
+// isPlausibleFloat reports whether the bits of v read as a float of a sensible magnitude.
+// Small integers are denormals as floats and are rejected here.
+func isPlausibleFloat(v uint32) bool {
+    f := math.Float32frombits(v)
+    if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+        return false
+    }
+    abs := math.Abs(float64(f))
+    return abs >= 1e-6 && abs <= 1e7
+}
+
+// isLocRun reports whether 17 columns look like a Loc field: a string column,
+// 15 string or empty columns and a non-string flags column
+func isLocRun(cols []inferredColumn) bool {
+    if cols[0].kind != inferString {
+        return false
+    }
+    for _, c := range cols[1:16] {
+        if c.kind != inferString && c.kind != inferZero {
+            return false
+        }
+    }
+    return cols[16].kind != inferString
+}
+
+// isUniqueID reports whether the first column holds unique non-zero values
+func isUniqueID(records []byte, count, recordSize int) bool {
+    if count == 0 {
+        return false
+    }
+    seen := make(map[uint32]struct{}, count)
+    for r := 0; r < count; r++ {
+        v := binary.LittleEndian.Uint32(records[r*recordSize:])
+        if _, dup := seen[v]; dup || v == 0 {
+            return false
+        }
+        seen[v] = struct{}{}
+    }
+    return true
+}
+
+// FormatMeta renders a meta in the layout of the files in meta/, one field per line
+func FormatMeta(meta *MetaFile) ([]byte, error) {
+    var buf bytes.Buffer
+    str := func(s string) string {
+        b, _ := json.Marshal(s)
+        return string(b)
+    }
+    list := func(items []string) string {
+        quoted := make([]string, len(items))
+        for i, s := range items {
+            quoted[i] = str(s)
+        }
+        return "[" + strings.Join(quoted, ", ") + "]"
+    }
+
+    buf.WriteString("{\n")
+    fmt.Fprintf(&buf, "  \"file\": %s,\n", str(meta.File))
+    if meta.Format != "" {
+        fmt.Fprintf(&buf, "  \"format\": %s,\n", str(meta.Format))
+    }
+    if meta.TableName != "" {
+        fmt.Fprintf(&buf, "  \"tableName\": %s,\n", str(meta.TableName))
+    }
+    fmt.Fprintf(&buf, "  \"primaryKeys\": %s,\n", list(meta.PrimaryKeys))
+    if len(meta.UniqueKeys) > 0 {
+        keys := make([]string, len(meta.UniqueKeys))
+        for i, uk := range meta.UniqueKeys {
+            keys[i] = list(uk)
+        }
+        fmt.Fprintf(&buf, "  \"uniqueKeys\": [%s],\n", strings.Join(keys, ", "))
+    }
+    if len(meta.SortOrder) > 0 {
+        buf.WriteString("  \"sortOrder\": [\n")
+        for i, sf := range meta.SortOrder {
+            sep := ","
+            if i == len(meta.SortOrder)-1 {
+                sep = ""
+            }
+            fmt.Fprintf(&buf, "    { \"name\": %s, \"direction\": %s }%s\n", str(sf.Name), str(sf.Direction), sep)
+        }
+        buf.WriteString("  ],\n")
+    }
+
+    buf.WriteString("  \"fields\": [\n")
+    for i, f := range meta.Fields {
+        fmt.Fprintf(&buf, "    { \"name\": %s, \"type\": %s", str(f.Name), str(f.Type))
+        if f.Count > 0 {
+            fmt.Fprintf(&buf, ", \"count\": %d", f.Count)
+        }
+        if f.Ref != nil {
+            fmt.Fprintf(&buf, ", \"ref\": { \"table\": %s", str(f.Ref.Table))
+            if f.Ref.Field != "" {
+                fmt.Fprintf(&buf, ", \"field\": %s", str(f.Ref.Field))
+            }
+            if len(f.Ref.None) > 0 {
+                none := make([]string, len(f.Ref.None))
+                for i, n := range f.Ref.None {
+                    none[i] = strconv.FormatInt(n, 10)
+                }
+                fmt.Fprintf(&buf, ", \"none\": [%s]", strings.Join(none, ", "))
+            }
+            buf.WriteString(" }")
+        }
+        if f.Note != "" {
+            fmt.Fprintf(&buf, ", \"note\": %s", str(f.Note))
+        }
+        buf.WriteString(" }")
+        if i < len(meta.Fields)-1 {
+            buf.WriteString(",")
+        }
+        buf.WriteString("\n")
+    }
+    buf.WriteString("  ]\n}\n")
+
+    // make sure the result still parses as a meta
+    var check MetaFile
+    if err := json.Unmarshal(buf.Bytes(), &check); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// draftMetaPath returns the default output path of an inferred meta
+func draftMetaPath(cfg *Config, dbcName string) string {
+    return filepath.Join(cfg.Paths.Meta, strings.ToLower(dbcName)+".meta.json.draft")
+}
@@ -0,0 +1,481 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "slices"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// jsonHeader is the header of a DBC in its JSON form. Counts and sizes are
+// informational and recomputed on load; the WDB2 fields are restored.
+type jsonHeader struct {
+    Magic           string `json:"magic"`
+    RecordCount     uint32 `json:"record_count"`
+    FieldCount      uint32 `json:"field_count"`
+    RecordSize      uint32 `json:"record_size"`
+    StringBlockSize uint32 `json:"string_block_size"`
+    TableHash       uint32 `json:"table_hash,omitempty"`
+    Build           uint32 `json:"build,omitempty"`
+    Timestamp       uint32 `json:"timestamp,omitempty"`
+    MinID           uint32 `json:"min_id,omitempty"`
+    MaxID           uint32 `json:"max_id,omitempty"`
+    Locale          uint32 `json:"locale,omitempty"`
+    Index           []byte `json:"index,omitempty"` // WDB2 id index and string lengths as stored in the file
+    CopyTable       []byte `json:"copy_table,omitempty"`
+}
+
+// jsonPreamble is everything but the records: the whole document for JSON
+// without its records, or the first line of NDJSON
+type jsonPreamble struct {
+    File       string          `json:"file"`
+    Header     jsonHeader      `json:"header"`
+    Strings    []string        `json:"strings"`
+    StringRefs []jsonStringRef `json:"string_refs,omitempty"`
+}
+
+// jsonStringRef pins a string cell to its offset when the text alone would resolve
+// to another copy, as happens in string blocks holding duplicates
+type jsonStringRef struct {
+    Record int    `json:"record"`
+    Column string `json:"column"`
+    Offset uint32 `json:"offset"`
+}
+
+// WriteJSON writes a DBC as a JSON document with one record per line, or as
+// NDJSON with the header on the first line and one record per following line.
+// Records are objects keyed by field name in meta order; arrays are JSON arrays,
+// Loc fields objects keyed by locale, and strings are resolved to text. The
+// string block is kept as a list so the file can be rebuilt byte for byte.
+func WriteJSON(w io.Writer, dbc *DBCFile, ndjson bool) error {
+    out := bufio.NewWriter(w)
+    h := dbc.Header
+    pre := jsonPreamble{
+        File: dbc.Table.Schema.Meta.File,
+        Header: jsonHeader{
+            Magic:           string(h.Magic[:]),
+            RecordCount:     h.RecordCount,
+            FieldCount:      h.FieldCount,
+            RecordSize:      h.RecordSize,
+            StringBlockSize: h.StringBlockSize,
+        },
+        Strings:    splitStringBlock(dbc.StringBlock),
+        StringRefs: stringRefs(dbc),
+    }
+    if h.Format() == FormatWDB2 {
+        jh := &pre.Header
+        jh.TableHash, jh.Build, jh.Timestamp, jh.Locale = h.TableHash, h.Build, h.Timestamp, h.Locale
+        jh.MinID, jh.MaxID = h.MinID, h.MaxID
+        if len(dbc.IndexTable) > 0 {
+            jh.Index = encodeWDB2Index(dbc.IndexTable, dbc.StringLengths)
+        }
+        jh.CopyTable = dbc.CopyTable
+    }
+
+    preData, err := json.Marshal(pre)
+    if err != nil {
+        return err
+    }
+
+    fieldCols := fieldColumns(dbc.Table.Schema)
+    if ndjson {
+        out.Write(preData)
+        out.WriteByte('\n')
+        for row := 0; row < dbc.Table.Len(); row++ {
+            out.Write(recordJSON(dbc, row, fieldCols))
+            out.WriteByte('\n')
+        }
+        return out.Flush()
+    }
+
+    // splice the records into the preamble object, one per line for readable diffs
+    out.Write(preData[:len(preData)-1])
+    out.WriteString(",\"records\":[")
+    for row := 0; row < dbc.Table.Len(); row++ {
+        if row > 0 {
+            out.WriteByte(',')
+        }
+        out.WriteString("\n")
+        out.Write(recordJSON(dbc, row, fieldCols))
+    }
+    out.WriteString("\n]}\n")
+    return out.Flush()
+}
+
+// fieldColumns groups the schema columns by the field they belong to
+func fieldColumns(schema *Schema) [][]int {
+    cols := make([][]int, len(schema.Meta.Fields))
+    for c, col := range schema.Columns {
+        cols[col.Field] = append(cols[col.Field], c)
+    }
+    return cols
+}
+
+// recordJSON encodes one record as an object keyed by field name
+func recordJSON(dbc *DBCFile, row int, fieldCols [][]int) []byte {
+    var buf bytes.Buffer
+    schema := dbc.Table.Schema
+    buf.WriteByte('{')
+    for fi, f := range schema.Meta.Fields {
+        if fi > 0 {
+            buf.WriteByte(',')
+        }
+        name, _ := json.Marshal(f.Name)
+        buf.Write(name)
+        buf.WriteByte(':')
+
+        cols := fieldCols[fi]
+        per := 1
+        if f.Type == "Loc" {
+            per = len(locLangs)
+        }
+        if f.Count > 1 {
+            buf.WriteByte('[')
+        }
+        for j := 0; j < len(cols); j += per {
+            if j > 0 {
+                buf.WriteByte(',')
+            }
+            if f.Type != "Loc" {
+                buf.WriteString(cellJSON(dbc, row, cols[j]))
+                continue
+            }
+            buf.WriteByte('{')
+            for k, lang := range locLangs {
+                if k > 0 {
+                    buf.WriteByte(',')
+                }
+                fmt.Fprintf(&buf, "%q:%s", lang, cellJSON(dbc, row, cols[j+k]))
+            }
+            buf.WriteByte('}')
+        }
+        if f.Count > 1 {
+            buf.WriteByte(']')
+        }
+    }
+    buf.WriteByte('}')
+    return buf.Bytes()
+}
+
+// cellJSON encodes a single value. Floats JSON cannot represent are strings:
+// "NaN", "Inf" and "-Inf", or "NaN:0x7FC00001" for NaNs with a payload.
+func cellJSON(dbc *DBCFile, row, c int) string {
+    switch dbc.Table.Schema.Columns[c].Type {
+    case "string":
+        text, _ := json.Marshal(dbc.Text(row, c))
+        return string(text)
+    case "float":
+        f := dbc.Table.Float(row, c)
+        switch {
+        case math.IsNaN(float64(f)):
+            if bits := math.Float32bits(f); bits != 0x7FC00000 {
+                return fmt.Sprintf("\"NaN:0x%08X\"", bits)
+            }
+            return "\"NaN\""
+        case math.IsInf(float64(f), 1):
+            return "\"Inf\""
+        case math.IsInf(float64(f), -1):
+            return "\"-Inf\""
+        }
+        return strconv.FormatFloat(float64(f), 'g', -1, 32)
+    default:
+        return formatCell(dbc, row, c)
+    }
+}
+
+// stringRefs lists the string cells whose offset differs from the one their text
+// resolves to on load
+func stringRefs(dbc *DBCFile) []jsonStringRef {
+    var refs []jsonStringRef
+    strs := newStringTable(splitStringBlock(dbc.StringBlock))
+    for row := 0; row < dbc.Table.Len(); row++ {
+        for c, col := range dbc.Table.Schema.Columns {
+            if col.Type != "string" {
+                continue
+            }
+            if off := uint32(dbc.Table.Raw(row, c)); strs.offset(dbc.Text(row, c)) != off {
+                refs = append(refs, jsonStringRef{Record: row, Column: col.Name, Offset: off})
+            }
+        }
+    }
+    return refs
+}
+
+// splitStringBlock lists the null-terminated strings of a string block in order
+func splitStringBlock(block []byte) []string {
+    if len(block) == 0 {
+        return []string{}
+    }
+    parts := strings.Split(string(block), "\x00")
+    // the block ends with a null, which leaves an empty last element
+    return parts[:len(parts)-1]
+}
+
+// ReadJSON builds a DBC from the output of WriteJSON, in either form
+func ReadJSON(r io.Reader, schema *Schema) (*DBCFile, error) {
+    dec := json.NewDecoder(r)
+    dec.UseNumber()
+
+    var top map[string]json.RawMessage
+    if err := dec.Decode(&top); err != nil {
+        return nil, fmt.Errorf("failed to read JSON: %w", err)
+    }
+    var pre jsonPreamble
+    for key, target := range map[string]interface{}{"file": &pre.File, "header": &pre.Header, "strings": &pre.Strings, "string_refs": &pre.StringRefs} {
+        if raw, ok := top[key]; ok {
+            if err := json.Unmarshal(raw, target); err != nil {
+                return nil, fmt.Errorf("invalid %s: %w", key, err)
+            }
+        }
+    }
+
+    dbc := &DBCFile{Table: NewTable(schema, int(pre.Header.RecordCount))}
+    copy(dbc.Header.Magic[:], schema.Meta.FileFormat())
+    if pre.Header.Magic != "" && pre.Header.Magic != schema.Meta.FileFormat() {
+        return nil, fmt.Errorf("JSON is %s but meta declares %s", pre.Header.Magic, schema.Meta.FileFormat())
+    }
+    strs := newStringTable(pre.Strings)
+    fieldCols := fieldColumns(schema)
+
+    addRecord := func(n int, raw json.RawMessage) error {
+        dec := json.NewDecoder(bytes.NewReader(raw))
+        dec.UseNumber()
+        var rec map[string]json.RawMessage
+        if err := dec.Decode(&rec); err != nil {
+            return fmt.Errorf("record %d: %w", n, err)
+        }
+        if err := setRecordJSON(dbc, dbc.Table.AppendRow(), rec, fieldCols, strs); err != nil {
+            return fmt.Errorf("record %d: %w", n, err)
+        }
+        return nil
+    }
+
+    if rawRecords, ok := top["records"]; ok {
+        var records []json.RawMessage
+        if err := json.Unmarshal(rawRecords, &records); err != nil {
+            return nil, fmt.Errorf("invalid records: %w", err)
+        }
+        for n, raw := range records {
+            if err := addRecord(n, raw); err != nil {
+                return nil, err
+            }
+        }
+    } else {
+        // NDJSON: the remaining lines are records
+        for n := 0; ; n++ {
+            var raw json.RawMessage
+            if err := dec.Decode(&raw); err == io.EOF {
+                break
+            } else if err != nil {
+                return nil, fmt.Errorf("record %d: %w", n, err)
+            }
+            if err := addRecord(n, raw); err != nil {
+                return nil, err
+            }
+        }
+    }
+    dbc.StringBlock = strs.block
+
+    // pinned offsets only apply while the record still holds the same text
+    for _, ref := range pre.StringRefs {
+        c, ok := schema.Lookup(ref.Column)
+        if !ok || ref.Record < 0 || ref.Record >= dbc.Table.Len() || schema.Columns[c].Type != "string" {
+            continue
+        }
+        if ref.Offset < uint32(len(dbc.StringBlock)) && readString(dbc.StringBlock, ref.Offset) == dbc.Text(ref.Record, c) {
+            dbc.Table.SetRaw(ref.Record, c, uint64(ref.Offset))
+        }
+    }
+
+    // restore the WDB2 fields through a stand-in for the previous file
+    var prev *DBCFile
+    if dbc.Header.Format() == FormatWDB2 {
+        jh := pre.Header
+        prev = &DBCFile{CopyTable: jh.CopyTable}
+        prev.Header = DBCHeader{Magic: dbc.Header.Magic, RecordCount: jh.RecordCount, TableHash: jh.TableHash,
+            Build: jh.Build, Timestamp: jh.Timestamp, Locale: jh.Locale, MinID: jh.MinID, MaxID: jh.MaxID}
+        if n := prev.Header.IndexEntries(); n > 0 && len(jh.Index) == n*6 {
+            prev.IndexTable = make([]uint32, n)
+            prev.StringLengths = make([]uint16, n)
+            for i := range prev.IndexTable {
+                prev.IndexTable[i] = binary.LittleEndian.Uint32(jh.Index[i*4:])
+                prev.StringLengths[i] = binary.LittleEndian.Uint16(jh.Index[n*4+i*2:])
+            }
+        }
+    }
+    if err := FinishHeader(dbc, prev); err != nil {
+        return nil, err
+    }
+    return dbc, nil
+}
+
+// setRecordJSON stores the fields of a decoded record object in a row. Keys that
+// are not fields of the meta are errors, as a misspelled field would load as zero.
+func setRecordJSON(dbc *DBCFile, row int, rec map[string]json.RawMessage, fieldCols [][]int, strs *stringTable) error {
+    schema := dbc.Table.Schema
+    matched := 0
+    for fi, f := range schema.Meta.Fields {
+        raw, ok := rec[f.Name]
+        if !ok {
+            continue
+        }
+        matched++
+        elems := []json.RawMessage{raw}
+        if f.Count > 1 {
+            elems = nil
+            if err := json.Unmarshal(raw, &elems); err != nil {
+                return fmt.Errorf("field %s: expected an array: %w", f.Name, err)
+            }
+            if len(elems) > int(f.Count) {
+                return fmt.Errorf("field %s: %d elements, meta allows %d", f.Name, len(elems), f.Count)
+            }
+        }
+
+        cols := fieldCols[fi]
+        for j, elem := range elems {
+            if f.Type != "Loc" {
+                if err := setCellJSON(dbc, row, cols[j], elem, strs); err != nil {
+                    return fmt.Errorf("field %s: %w", f.Name, err)
+                }
+                continue
+            }
+            var loc map[string]json.RawMessage
+            if err := json.Unmarshal(elem, &loc); err != nil {
+                return fmt.Errorf("field %s: expected a Loc object: %w", f.Name, err)
+            }
+            found := 0
+            for k, lang := range locLangs {
+                if v, ok := loc[lang]; ok {
+                    found++
+                    if err := setCellJSON(dbc, row, cols[j*len(locLangs)+k], v, strs); err != nil {
+                        return fmt.Errorf("field %s.%s: %w", f.Name, lang, err)
+                    }
+                }
+            }
+            if found < len(loc) {
+                return fmt.Errorf("field %s: unknown locale %s", f.Name, unknownKey(loc, locLangs))
+            }
+        }
+    }
+    if matched < len(rec) {
+        names := make([]string, len(schema.Meta.Fields))
+        for i, f := range schema.Meta.Fields {
+            names[i] = f.Name
+        }
+        return fmt.Errorf("unknown field %s", unknownKey(rec, names))
+    }
+    return nil
+}
+
+// unknownKey returns the first key of an object, in sorted order, that is not in known
+func unknownKey(obj map[string]json.RawMessage, known []string) string {
+    var keys []string
+    for key := range obj {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+        if !slices.Contains(known, key) {
+            return strconv.Quote(key)
+        }
+    }
+    return ""
+}
+
+// setCellJSON stores a single decoded value
+func setCellJSON(dbc *DBCFile, row, c int, raw json.RawMessage, strs *stringTable) error {
+    col := dbc.Table.Schema.Columns[c]
+    if col.Type == "string" {
+        var text string
+        if err := json.Unmarshal(raw, &text); err != nil {
+            return err
+        }
+        dbc.Table.SetRaw(row, c, uint64(strs.offset(text)))
+        return nil
+    }
+
+    var text string
+    if col.Type == "float" && json.Unmarshal(raw, &text) == nil {
+        bits, err := parseFloatName(text)
+        if err != nil {
+            return err
+        }
+        dbc.Table.SetRaw(row, c, uint64(bits))
+        return nil
+    }
+
+    var n json.Number
+    if err := json.Unmarshal(raw, &n); err != nil {
+        return err
+    }
+    bits, err := parseCell(col, n.String())
+    if err != nil {
+        return err
+    }
+    dbc.Table.SetRaw(row, c, bits)
+    return nil
+}
+
+// parseFloatName parses the string forms cellJSON uses for NaN and infinities
+func parseFloatName(s string) (uint32, error) {
+    switch {
+    case s == "NaN":
+        return 0x7FC00000, nil
+    case s == "Inf":
+        return math.Float32bits(float32(math.Inf(1))), nil
+    case s == "-Inf":
+        return math.Float32bits(float32(math.Inf(-1))), nil
+    case strings.HasPrefix(s, "NaN:"):
+        bits, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(s, "NaN:"), "0x"), 16, 32)
+        return uint32(bits), err
+    }
+    return 0, fmt.Errorf("invalid float %q", s)
+}
+
+// stringTable rebuilds a string block from a list of strings, reusing the
+// offsets of listed strings so the original block layout is preserved
+type stringTable struct {
+    block   []byte
+    offsets map[string]uint32
+}
+
+func newStringTable(list []string) *stringTable {
+    t := &stringTable{block: []byte{0}, offsets: map[string]uint32{"": 0}}
+    if len(list) > 0 {
+        t.block = t.block[:0]
+        t.offsets = map[string]uint32{}
+        for _, s := range list {
+            if _, ok := t.offsets[s]; !ok {
+                t.offsets[s] = uint32(len(t.block))
+            }
+            t.block = append(t.block, s...)
+            t.block = append(t.block, 0)
+        }
+    }
+    return t
+}
+
+// offset returns the offset of a string, appending it if it is not in the block
+func (t *stringTable) offset(s string) uint32 {
+    if off, ok := t.offsets[s]; ok {
+        return off
+    }
+    // strings may point into the tail of a longer one
+    if i := bytes.Index(t.block, append([]byte(s), 0)); i >= 0 {
+        t.offsets[s] = uint32(i)
+        return uint32(i)
+    }
+    return getStringOffset(s, &t.block, t.offsets)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestReadJSONUnknownKeys(t *testing.T) {
+    schema := queryTestDBC(t).Table.Schema
+    tests := []struct {
+        record string
+        err    string
+    }{
+        {`{"id":1,"nmae":{"enus":"x"}}`, `unknown field "nmae"`},
+        {`{"id":1,"name":{"enUS":"x"}}`, `field name: unknown locale "enUS"`},
+        {`{"id":1,"name":{"enus":"x"},"effect":[1,2,3]}`, ""},
+    }
+    for _, tt := range tests {
+        doc := `{"header":{"magic":"WDBC"},"records":[` + tt.record + `]}`
+        dbc, err := ReadJSON(strings.NewReader(doc), schema)
+        if tt.err == "" {
+            if err != nil {
+                t.Errorf("%s: %v", tt.record, err)
+            } else if dbc.Text(0, 2) != "x" {
+                t.Errorf("%s: name = %q", tt.record, dbc.Text(0, 2))
+            }
+            continue
+        }
+        if err == nil || !strings.Contains(err.Error(), tt.err) {
+            t.Errorf("%s: got error %v, want %q", tt.record, err, tt.err)
+        }
+    }
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+    dbc := queryTestDBC(t)
+    copy(dbc.Header.Magic[:], FormatWDBC)
+    for _, ndjson := range []bool{false, true} {
+        var buf bytes.Buffer
+        if err := WriteJSON(&buf, dbc, ndjson); err != nil {
+            t.Fatal(err)
+        }
+        loaded, err := ReadJSON(&buf, dbc.Table.Schema)
+        if err != nil {
+            t.Fatalf("ndjson=%v: %v", ndjson, err)
+        }
+        for row := 0; row < dbc.Table.Len(); row++ {
+            for c := range dbc.Table.Schema.Columns {
+                if loaded.Table.Raw(row, c) != dbc.Table.Raw(row, c) {
+                    t.Errorf("ndjson=%v: record %d column %d differs", ndjson, row, c)
+                }
+            }
+        }
+        if !bytes.Equal(loaded.StringBlock, dbc.StringBlock) {
+            t.Errorf("ndjson=%v: string block differs", ndjson)
+        }
+    }
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "fmt"
+    "strings"
+)
+
+// LintMeta checks a meta file for mistakes that would otherwise only show up at
+// import time, or not at all. If header is not nil the layout is also checked
+// against the DBC it describes.
+func LintMeta(meta *MetaFile, header *DBCHeader) []string {
+    var problems []string
+    report := func(format string, args ...interface{}) {
+        problems = append(problems, fmt.Sprintf(format, args...))
+    }
+
+    if meta.File == "" {
+        report("file is empty")
+    }
+    if format := meta.FileFormat(); format != FormatWDBC && format != FormatWDB2 {
+        report("unknown format %q, expected %s or %s", meta.Format, FormatWDBC, FormatWDB2)
+    }
+    if len(meta.Fields) == 0 {
+        report("no fields defined")
+    }
+
+    // field names and types; an invalid type stops the column checks below
+    validTypes := true
+    fieldNames := map[string]int{}
+    dupNames := map[string]bool{}
+    for i, f := range meta.Fields {
+        if f.Name == "" {
+            report("field #%d has no name", i)
+        } else if prev, dup := fieldNames[f.Name]; dup {
+            report("field #%d: duplicate field name %q (also field #%d)", i, f.Name, prev)
+            dupNames[f.Name] = true
+        } else {
+            fieldNames[f.Name] = i
+        }
+        if f.Type != "Loc" {
+            if _, err := fieldSize(f.Type); err != nil {
+                report("field #%d %q: unknown type %q", i, f.Name, f.Type)
+                validTypes = false
+            }
+        }
+        if f.Ref != nil {
+            if f.Ref.Table == "" {
+                report("field #%d %q: ref has no table", i, f.Name)
+            }
+            switch f.Type {
+            case "float", "string", "Loc":
+                report("field #%d %q: ref on a %s field, references must be integers", i, f.Name, f.Type)
+            }
+        }
+    }
+    if !validTypes {
+        return problems
+    }
+
+    schema, err := NewSchema(meta)
+    if err != nil {
+        report("%v", err)
+        return problems
+    }
+
+    // expanded names may still collide, e.g. an array "icon" next to a field "icon_1"
+    columnNames := map[string]int{}
+    for _, col := range schema.Columns {
+        columnNames[col.Name]++
+    }
+    for _, col := range schema.Columns {
+        if n := columnNames[col.Name]; n > 1 && !dupNames[col.Name] {
+            report("column %q is defined %d times after expanding arrays and Loc fields", col.Name, n)
+            columnNames[col.Name] = 0
+        }
+    }
+
+    // createTable adds the auto_id surrogate when none of the primary keys is a column
+    surrogate := true
+    for _, pk := range meta.PrimaryKeys {
+        if _, ok := schema.Lookup(pk); ok {
+            surrogate = false
+        }
+    }
+    if len(meta.PrimaryKeys) == 0 {
+        report("primaryKeys is empty, use [\"auto_id\"] for a surrogate key")
+    }
+
+    checkColumn := func(where, name string) {
+        if _, ok := schema.Lookup(name); ok {
+            return
+        }
+        if name == "auto_id" && surrogate {
+            return
+        }
+        report("%s: %q is not a column%s", where, name, columnHint(meta, name))
+    }
+
+    for _, pk := range meta.PrimaryKeys {
+        checkColumn("primaryKeys", pk)
+    }
+    for i, uk := range meta.UniqueKeys {
+        if len(uk) == 0 {
+            report("uniqueKeys #%d is empty", i)
+        }
+        for _, name := range uk {
+            checkColumn(fmt.Sprintf("uniqueKeys #%d", i), name)
+        }
+    }
+    for _, sf := range meta.SortOrder {
+        checkColumn("sortOrder", sf.Name)
+        dir := strings.ToUpper(sf.Direction)
+        if dir != "" && dir != "ASC" && dir != "DESC" {
+            report("sortOrder %q: direction %q is not ASC or DESC", sf.Name, sf.Direction)
+        }
+    }
+
+    if meta.FileFormat() == FormatWDB2 && len(schema.Columns) > 0 {
+        if t := schema.Columns[0].Type; t != "int32" && t != "uint32" {
+            report("WDB2 id field %q must be int32 or uint32, got %s", schema.Columns[0].Name, t)
+        }
+    }
+
+    if header == nil {
+        return problems
+    }
+
+    if header.Format() != meta.FileFormat() {
+        report("format mismatch: file is %s but meta declares %s", header.Format(), meta.FileFormat())
+    }
+    sizeMismatch := uint32(schema.RecordSize) != header.RecordSize
+    if sizeMismatch {
+        report("record size mismatch: meta defines %d bytes, header has %d (%+d)",
+            schema.RecordSize, header.RecordSize, int(header.RecordSize)-schema.RecordSize)
+    }
+    if uint32(schema.FieldCount) != header.FieldCount {
+        report("field count mismatch: meta defines %d columns, header has %d (%+d)",
+            schema.FieldCount, header.FieldCount, int(header.FieldCount)-schema.FieldCount)
+    }
+    if sizeMismatch || uint32(schema.FieldCount) != header.FieldCount {
+        report("field offsets: %s", fieldOffsets(meta, schema, int(header.RecordSize)))
+    }
+
+    return problems
+}
+
+// columnHint suggests the expanded column name when an array or Loc base name is used
+func columnHint(meta *MetaFile, name string) string {
+    for _, f := range meta.Fields {
+        if f.Name != name {
+            continue
+        }
+        switch {
+        case f.Type == "Loc" && f.Count > 1:
+            return fmt.Sprintf(" (Loc array, use e.g. %s_1_%s)", name, locLangs[0])
+        case f.Type == "Loc":
+            return fmt.Sprintf(" (Loc field, use e.g. %s_%s)", name, locLangs[0])
+        case f.Count > 1:
+            return fmt.Sprintf(" (array field, use %s_1 … %s_%d)", name, name, f.Count)
+        }
+    }
+    return ""
+}
+
+// fieldOffsets lists the byte range of every field, marking those past the record end
+func fieldOffsets(meta *MetaFile, schema *Schema, recordSize int) string {
+    parts := make([]string, 0, len(meta.Fields))
+    for _, col := range schema.Columns {
+        // one entry per field, starting at its first column
+        if col.Index != 0 || col.Locale > 0 {
+            continue
+        }
+        f := meta.Fields[col.Field]
+        end := col.Offset
+        for _, c := range schema.Columns {
+            if c.Field == col.Field && c.Offset+c.Size > end {
+                end = c.Offset + c.Size
+            }
+        }
+        entry := fmt.Sprintf("%s@%d-%d", f.Name, col.Offset, end)
+        if end > recordSize {
+            entry += "!"
+        }
+        parts = append(parts, entry)
+    }
+    return strings.Join(parts, " ") + fmt.Sprintf(" (record is %d bytes, ! = past the end)", recordSize)
+}
+
+// LintRefs checks that the tables and fields referenced by a meta exist
+func LintRefs(meta *MetaFile, metaDir string) []string {
+    var problems []string
+    for i, f := range meta.Fields {
+        if f.Ref == nil || f.Ref.Table == "" {
+            continue
+        }
+        if _, _, err := refTarget(metaDir, f.Ref); err != nil {
+            problems = append(problems, fmt.Sprintf("field #%d %q: ref: %v", i, f.Name, err))
+        }
+    }
+    return problems
+}
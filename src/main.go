@@ -6,12 +6,15 @@
 package main
 
 import (
+    "bytes"
+    "encoding/json"
     "flag"
     "fmt"
     "log"
     "os"
     "path/filepath"
     "strings"
+    "text/tabwriter"
 )
 
 func main() {
@@ -87,6 +90,32 @@ func main() {
             handleExport(cfg, subArgs)
         case "verify", "check":
             handleVerify(cfg, subArgs)
+        case "infer":
+            handleInfer(cfg, subArgs)
+        case "lint":
+            handleLint(cfg, subArgs)
+        case "dbd":
+            handleDBD(cfg, subArgs)
+        case "diff":
+            handleDiff(cfg, subArgs)
+        case "merge":
+            handleMerge(cfg, subArgs)
+        case "csv":
+            handleCSV(cfg, subArgs)
+        case "dump":
+            handleDump(cfg, subArgs)
+        case "records":
+            handleRecords(cfg, subArgs)
+        case "load":
+            handleLoad(cfg, subArgs)
+        case "edit":
+            handleEdit(cfg, subArgs)
+        case "query":
+            handleQuery(cfg, subArgs)
+        case "grep":
+            handleGrep(cfg, subArgs)
+        case "check-refs":
+            handleCheckRefs(cfg, subArgs)
         default:
             fmt.Printf("Unknown command: %s\n\n", cmd)
             printUsage()
@@ -121,11 +150,11 @@ func handleRead(cfg *Config, args []string) {
 
     fmt.Printf("Read %s:\n", meta.File)
     fmt.Printf("Record %d sample:\n", *record)
-    PrintRecord(dbc.Records[*record], meta, dbc.StringBlock)
+    PrintRecord(dbc, *record)
 
     if *writeOut {
         outPath := filepath.Join(cfg.Paths.Export, meta.File)
-        if err := WriteDBC(dbc, meta, outPath); err != nil {
+        if err := WriteDBC(dbc, outPath); err != nil {
             log.Fatalf("Failed to rebuild DBC: %v", err)
         }
         fmt.Printf("\n%s written to %s\n", meta.File, outPath)
@@ -155,6 +184,15 @@ func handleHeader(cfg *Config, args []string) {
     fmt.Printf("  Field Count: %d\n", header.FieldCount)
     fmt.Printf("  Record Size: %d bytes\n", header.RecordSize)
     fmt.Printf("  String Block Size: %d bytes\n", header.StringBlockSize)
+    if header.Format() == FormatWDB2 {
+        fmt.Printf("  Table Hash: 0x%08X\n", header.TableHash)
+        fmt.Printf("  Build: %d\n", header.Build)
+        fmt.Printf("  Timestamp: %d\n", header.Timestamp)
+        fmt.Printf("  Min ID: %d\n", header.MinID)
+        fmt.Printf("  Max ID: %d\n", header.MaxID)
+        fmt.Printf("  Locale: %d\n", header.Locale)
+        fmt.Printf("  Copy Table Size: %d bytes\n", header.CopyTableSize)
+    }
 }
 
 func handleImport(cfg *Config, args []string) {
@@ -163,9 +201,28 @@ func handleImport(cfg *Config, args []string) {
     importCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
     force := importCmd.Bool("force", false, "Force import a DBC. This will drop any existing data!")
     importCmd.BoolVar(force, "f", false, "Force import (shorthand). This will drop any existing data!")
+    sqlOut := importCmd.String("sql-out", "", "Write the SQL statements to one file per table in this directory instead of connecting")
+    gz := importCmd.Bool("gzip", false, "Gzip the files written by --sql-out")
+    importCmd.BoolVar(gz, "z", false, "Gzip SQL files (shorthand)")
+    dialectName := importCmd.String("dialect", "", "Server the --sql-out files are for: mysql57, mysql8 or mariadb (default: dbc.driver, or mysql57)")
     importCmd.Parse(args)
 
-    dbcDB, err := openDB(cfg.DBC)
+    if *sqlOut != "" {
+        if *dialectName == "" {
+            *dialectName = cfg.DBC.Driver
+        }
+        dialect, err := dumpDialect(*dialectName)
+        if err != nil {
+            log.Fatalf("SQL dump failed: %v", err)
+        }
+        if err := DumpDBCs(*sqlOut, *dbcName, dialect, *gz, *force, cfg); err != nil {
+            log.Fatalf("SQL dump failed: %v", err)
+        }
+        log.Println("SQL dump completed successfully!")
+        return
+    }
+
+    dbcDB, err := openDB(cfg.DBC, cfg.Options.ForeignKeys)
     if err != nil {
         log.Fatalf("Failed to connect to DBC DB: %v", err)
     }
@@ -180,6 +237,11 @@ func handleImport(cfg *Config, args []string) {
         if err := ImportDBC(dbcDB, *force, cfg, metaPath); err != nil {
             log.Fatalf("Import failed for %s: %v", *dbcName, err)
         }
+        if cfg.Options.ForeignKeys {
+            if err := AddForeignKeys(dbcDB, cfg); err != nil {
+                log.Fatalf("Import failed: %v", err)
+            }
+        }
     }
 
     log.Println("Import completed successfully!")
@@ -191,36 +253,55 @@ func handleExport(cfg *Config, args []string) {
     exportCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
     force := exportCmd.Bool("force", false, "Force export even if versioning is enabled")
     exportCmd.BoolVar(force, "f", false, "Force export (shorthand)")
+    mpqPath := exportCmd.String("mpq", "", "Also write the exported DBCs into this MPQ archive (created if missing)")
+    compress := exportCmd.Bool("compress", false, "Compress files written to the MPQ archive")
+    exportCmd.BoolVar(compress, "z", false, "Compress MPQ files (shorthand)")
     exportCmd.Parse(args)
 
     if *force {
         cfg.Options.UseVersioning = false
     }
 
-    dbcDB, err := openDB(cfg.DBC)
+    dbcDB, err := openDB(cfg.DBC, false)
     if err != nil {
         log.Fatalf("Failed to connect to DBC DB: %v", err)
     }
     defer dbcDB.Close()
 
+    var metas []string
     if *dbcName == "" {
         if err := ExportDBCs(dbcDB, cfg); err != nil {
             log.Fatalf("Export failed: %v", err)
         }
+        all, err := filepath.Glob(filepath.Join(cfg.Paths.Meta, "*.meta.json"))
+        if err != nil {
+            log.Fatalf("Failed to scan meta directory: %v", err)
+        }
+        metas = all
     } else {
         metaPath := filepath.Join(cfg.Paths.Meta, *dbcName+".meta.json")
         if err := ExportDBC(dbcDB, cfg, metaPath); err != nil {
             log.Fatalf("Export failed for %s: %v", *dbcName, err)
         }
+        metas = []string{metaPath}
     }
 
     log.Println("Export completed successfully!")
+
+    if *mpqPath != "" {
+        if err := PackExportMPQ(cfg, metas, *mpqPath, *compress); err != nil {
+            log.Fatalf("Failed to write MPQ %s: %v", *mpqPath, err)
+        }
+        log.Printf("Exported DBCs written to %s", *mpqPath)
+    }
 }
 
 func handleVerify(cfg *Config, args []string) {
     verifyCmd := flag.NewFlagSet("verify", flag.ExitOnError)
     dbcName := verifyCmd.String("name", "", "DBC file name")
     verifyCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    detail := verifyCmd.Bool("detail", false, "Report header and record differences of mismatching files")
+    verifyCmd.BoolVar(detail, "d", false, "Report differences (shorthand)")
     verifyCmd.Parse(args)
 
     // scan all metas or just one
@@ -246,22 +327,28 @@ func handleVerify(cfg *Config, args []string) {
             continue
         }
 
-        srcPath := filepath.Join(cfg.Paths.Base, meta.File)
-        outPath := filepath.Join(cfg.Paths.Export, meta.File)
-
-        same, err := compareFiles(srcPath, outPath)
+        srcData, _, err := readDBCData(cfg, meta.File)
+        if err != nil {
+            log.Printf("Error comparing %s: %v", meta.File, err)
+            failCount++
+            continue
+        }
+        outData, err := os.ReadFile(filepath.Join(cfg.Paths.Export, meta.File))
         if err != nil {
             log.Printf("Error comparing %s: %v", meta.File, err)
             failCount++
             continue
         }
 
-        if same {
+        if bytes.Equal(srcData, outData) {
             log.Printf("✓ Verified %s (identical)", meta.File)
             okCount++
         } else {
             log.Printf("✗ Mismatch in %s", meta.File)
             failCount++
+            if *detail {
+                printVerifyDetail(meta, srcData, outData)
+            }
         }
     }
 
@@ -271,6 +358,845 @@ func handleVerify(cfg *Config, args []string) {
     }
 }
 
+func handleInfer(cfg *Config, args []string) {
+    inferCmd := flag.NewFlagSet("infer", flag.ExitOnError)
+    dbcName := inferCmd.String("name", "", "DBC file name (without extension)")
+    inferCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    outPath := inferCmd.String("out", "", "Output path (default: <meta>/<name>.meta.json.draft)")
+    inferCmd.StringVar(outPath, "o", "", "Output path (shorthand)")
+    inferCmd.Parse(args)
+
+    if *dbcName == "" {
+        fmt.Println("Error: --name/-n is required for infer")
+        inferCmd.Usage()
+        return
+    }
+
+    file := resolveDBCFile(*dbcName, cfg)
+    data, source, err := readDBCData(cfg, file)
+    if err != nil {
+        log.Fatalf("Failed to read DBC: %v", err)
+    }
+
+    meta, err := InferMeta(data, filepath.Base(file))
+    if err != nil {
+        log.Fatalf("Failed to infer meta for %s: %v", source, err)
+    }
+    out, err := FormatMeta(meta)
+    if err != nil {
+        log.Fatalf("Failed to format meta: %v", err)
+    }
+
+    if *outPath == "" {
+        *outPath = draftMetaPath(cfg, *dbcName)
+    }
+    if err := os.WriteFile(*outPath, out, 0644); err != nil {
+        log.Fatalf("Failed to write %s: %v", *outPath, err)
+    }
+
+    fmt.Printf("Inferred %d fields for %s:\n", len(meta.Fields), file)
+    for _, f := range meta.Fields {
+        fmt.Printf("  %-12s %-7s %s\n", f.Name, f.Type, f.Note)
+    }
+    fmt.Printf("\nDraft written to %s; review it and rename to .meta.json\n", *outPath)
+}
+
+func handleLint(cfg *Config, args []string) {
+    lintCmd := flag.NewFlagSet("lint", flag.ExitOnError)
+    dbcName := lintCmd.String("name", "", "DBC file name")
+    lintCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    lintCmd.Parse(args)
+
+    metas := []string{}
+    if *dbcName == "" {
+        all, err := filepath.Glob(filepath.Join(cfg.Paths.Meta, "*.meta.json"))
+        if err != nil {
+            log.Fatalf("Failed to scan meta directory: %v", err)
+        }
+        metas = all
+    } else {
+        metas = []string{filepath.Join(cfg.Paths.Meta, *dbcName+".meta.json")}
+    }
+
+    okCount := 0
+    failCount := 0
+
+    for _, metaPath := range metas {
+        meta, err := LoadMeta(metaPath)
+        if err != nil {
+            log.Printf("✗ %v", err)
+            failCount++
+            continue
+        }
+
+        // the header checks need the DBC, the rest can run without it
+        var header *DBCHeader
+        if data, _, err := readDBCData(cfg, meta.File); err == nil {
+            if h, err := ParseHeader(data); err == nil {
+                header = &h
+            } else {
+                log.Printf("Warning: %s: %v", meta.File, err)
+            }
+        } else {
+            log.Printf("Warning: %s not found, skipping header checks", meta.File)
+        }
+
+        problems := append(LintMeta(&meta, header), LintRefs(&meta, cfg.Paths.Meta)...)
+        if len(problems) == 0 {
+            okCount++
+            continue
+        }
+
+        log.Printf("✗ %s", filepath.Base(metaPath))
+        for _, p := range problems {
+            log.Printf("    %s", p)
+        }
+        failCount++
+    }
+
+    log.Printf("Lint complete: %d ok, %d with problems", okCount, failCount)
+    if failCount > 0 {
+        os.Exit(1)
+    }
+}
+
+func handleDBD(cfg *Config, args []string) {
+    dbdCmd := flag.NewFlagSet("dbd", flag.ExitOnError)
+    dbdPath := dbdCmd.String("dbd", "", "Path to the WoWDBDefs .dbd file")
+    dbdCmd.StringVar(dbdPath, "d", "", "Path to the .dbd file (shorthand)")
+    build := dbdCmd.String("build", "", "Client build, e.g. 3.3.5.12340")
+    dbdCmd.StringVar(build, "b", "", "Client build (shorthand)")
+    file := dbdCmd.String("file", "", "Client file name (default: <dbd name>.dbc)")
+    outPath := dbdCmd.String("out", "", "Output path (default: <meta>/<name>.meta.json)")
+    dbdCmd.StringVar(outPath, "o", "", "Output path (shorthand)")
+    force := dbdCmd.Bool("force", false, "Overwrite an existing meta file")
+    dbdCmd.BoolVar(force, "f", false, "Overwrite an existing meta file (shorthand)")
+    dbdCmd.Parse(args)
+
+    if *dbdPath == "" || *build == "" {
+        fmt.Println("Error: --dbd/-d and --build/-b are required for dbd")
+        dbdCmd.Usage()
+        return
+    }
+
+    f, err := os.Open(*dbdPath)
+    if err != nil {
+        log.Fatalf("Failed to open %s: %v", *dbdPath, err)
+    }
+    dbd, err := ParseDBD(f)
+    f.Close()
+    if err != nil {
+        log.Fatalf("Failed to parse %s: %v", *dbdPath, err)
+    }
+
+    def, err := dbd.Find(*build)
+    if err != nil {
+        log.Fatalf("%s: %v", *dbdPath, err)
+    }
+
+    name := strings.TrimSuffix(filepath.Base(*dbdPath), filepath.Ext(*dbdPath))
+    if *file == "" {
+        *file = name + ".dbc"
+    }
+    meta, err := DBDToMeta(dbd, def, *file, *build)
+    if err != nil {
+        log.Fatalf("Failed to convert %s: %v", *dbdPath, err)
+    }
+    out, err := FormatMeta(meta)
+    if err != nil {
+        log.Fatalf("Failed to format meta: %v", err)
+    }
+
+    if *outPath == "" {
+        *outPath = filepath.Join(cfg.Paths.Meta, strings.ToLower(name)+".meta.json")
+    }
+    if _, err := os.Stat(*outPath); err == nil && !*force {
+        log.Fatalf("%s already exists, use --force to overwrite", *outPath)
+    }
+    if err := os.WriteFile(*outPath, out, 0644); err != nil {
+        log.Fatalf("Failed to write %s: %v", *outPath, err)
+    }
+
+    log.Printf("Wrote %s (%d fields) for build %s", *outPath, len(meta.Fields), *build)
+}
+
+// printVerifyDetail parses both versions of a file and prints what differs
+func printVerifyDetail(meta MetaFile, srcData, outData []byte) {
+    src, err := ParseDBC(srcData, meta.File, meta)
+    if err != nil {
+        log.Printf("    cannot parse original: %v", err)
+        return
+    }
+    out, err := ParseDBC(outData, filepath.Join("export", meta.File), meta)
+    if err != nil {
+        log.Printf("    cannot parse export: %v", err)
+        return
+    }
+
+    diff, err := DiffDBC(&src, &out, DiffOptions{})
+    if err != nil {
+        log.Printf("    cannot compare: %v", err)
+        return
+    }
+    if diff.Empty() {
+        fmt.Println("  no record differences; only the string block layout or padding differs")
+        return
+    }
+    PrintDiff(os.Stdout, diff)
+}
+
+func handleDiff(cfg *Config, args []string) {
+    diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+    dbcName := diffCmd.String("name", "", "DBC file name (selects the meta)")
+    diffCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    leftPath := diffCmd.String("left", "", "Left file (default: the original from paths.base or the MPQs)")
+    diffCmd.StringVar(leftPath, "l", "", "Left file (shorthand)")
+    rightPath := diffCmd.String("right", "", "Right file (default: the file in the export directory)")
+    diffCmd.StringVar(rightPath, "r", "", "Right file (shorthand)")
+    format := diffCmd.String("format", "text", "Output format: text, json or unified")
+    tolerance := diffCmd.Float64("float-tolerance", 0, "Treat floats closer than this as equal")
+    ignore := diffCmd.String("ignore", "", "Comma-separated columns or fields to ignore")
+    diffCmd.Parse(args)
+
+    if *dbcName == "" {
+        fmt.Println("Error: --name/-n is required for diff")
+        diffCmd.Usage()
+        return
+    }
+    if *format != "text" && *format != "json" && *format != "unified" {
+        log.Fatalf("Unknown format %q, expected text, json or unified", *format)
+    }
+
+    metaPath := filepath.Join(cfg.Paths.Meta, *dbcName+".meta.json")
+    meta, err := LoadMeta(metaPath)
+    if err != nil {
+        log.Fatalf("Failed to load meta: %v", err)
+    }
+
+    load := func(path string, fallback func() ([]byte, string, error)) (*DBCFile, string) {
+        var data []byte
+        var err error
+        if path == "" {
+            data, path, err = fallback()
+        } else {
+            data, err = os.ReadFile(path)
+        }
+        if err != nil {
+            log.Fatalf("Failed to read DBC: %v", err)
+        }
+        dbc, err := ParseDBC(data, path, meta)
+        if err != nil {
+            log.Fatalf("Failed to parse %s: %v", path, err)
+        }
+        return &dbc, path
+    }
+    left, leftName := load(*leftPath, func() ([]byte, string, error) {
+        return readDBCData(cfg, meta.File)
+    })
+    right, rightName := load(*rightPath, func() ([]byte, string, error) {
+        path := filepath.Join(cfg.Paths.Export, meta.File)
+        data, err := os.ReadFile(path)
+        return data, path, err
+    })
+
+    opts := DiffOptions{FloatTolerance: *tolerance, Ignore: map[string]bool{}}
+    for _, name := range strings.Split(*ignore, ",") {
+        if name = strings.TrimSpace(name); name != "" {
+            opts.Ignore[name] = true
+        }
+    }
+
+    diff, err := DiffDBC(left, right, opts)
+    if err != nil {
+        log.Fatalf("Failed to compare: %v", err)
+    }
+
+    switch *format {
+    case "json":
+        out, err := json.MarshalIndent(diff, "", "  ")
+        if err != nil {
+            log.Fatalf("Failed to encode diff: %v", err)
+        }
+        fmt.Println(string(out))
+    case "unified":
+        PrintUnifiedDiff(os.Stdout, left, right, leftName, rightName, diff)
+    default:
+        fmt.Printf("Diff %s -> %s:\n", leftName, rightName)
+        PrintDiff(os.Stdout, diff)
+    }
+
+    // like diff(1): exit status 1 when the files differ
+    if !diff.Empty() {
+        os.Exit(1)
+    }
+}
+
+func handleMerge(cfg *Config, args []string) {
+    mergeCmd := flag.NewFlagSet("merge", flag.ExitOnError)
+    dbcName := mergeCmd.String("name", "", "DBC file name (selects the meta)")
+    mergeCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    basePath := mergeCmd.String("base", "", "Common ancestor (file, or schema with --schemas)")
+    oursPath := mergeCmd.String("ours", "", "Our version (file, or schema with --schemas)")
+    theirsPath := mergeCmd.String("theirs", "", "Their version (file, or schema with --schemas)")
+    schemas := mergeCmd.Bool("schemas", false, "Read base, ours and theirs from MySQL schemas on the configured server")
+    outPath := mergeCmd.String("out", "", "Output file (default: the file in the export directory)")
+    mergeCmd.StringVar(outPath, "o", "", "Output file (shorthand)")
+    prefer := mergeCmd.String("prefer", "ours", "Side whose value is written for conflicts: ours or theirs")
+    reportPath := mergeCmd.String("report", "", "Also write the conflict report as JSON to this file")
+    mergeCmd.Parse(args)
+
+    if *dbcName == "" || *basePath == "" || *oursPath == "" || *theirsPath == "" {
+        fmt.Println("Error: --name/-n, --base, --ours and --theirs are required for merge")
+        mergeCmd.Usage()
+        return
+    }
+
+    metaPath := filepath.Join(cfg.Paths.Meta, *dbcName+".meta.json")
+    meta, err := LoadMeta(metaPath)
+    if err != nil {
+        log.Fatalf("Failed to load meta: %v", err)
+    }
+
+    load := func(source string) *DBCFile {
+        if *schemas {
+            dbCfg := cfg.DBC
+            dbCfg.Name = source
+            db, err := openDB(dbCfg, false)
+            if err != nil {
+                log.Fatalf("Failed to connect to schema %s: %v", source, err)
+            }
+            defer db.Close()
+            dbc, err := LoadTable(db, resolveTableName(&meta, cfg), meta)
+            if err != nil {
+                log.Fatalf("Failed to load %s: %v", source, err)
+            }
+            return dbc
+        }
+        dbc, err := LoadDBC(source, meta)
+        if err != nil {
+            log.Fatalf("Failed to load %s: %v", source, err)
+        }
+        return &dbc
+    }
+    base, ours, theirs := load(*basePath), load(*oursPath), load(*theirsPath)
+
+    merged, conflicts, err := MergeDBC(base, ours, theirs, *prefer)
+    if err != nil {
+        log.Fatalf("Merge failed: %v", err)
+    }
+
+    if *outPath == "" {
+        *outPath = filepath.Join(cfg.Paths.Export, meta.File)
+        if err := os.MkdirAll(cfg.Paths.Export, 0755); err != nil {
+            log.Fatalf("Failed to create export directory: %v", err)
+        }
+    }
+    if err := WriteDBC(merged, *outPath); err != nil {
+        log.Fatalf("Failed to write %s: %v", *outPath, err)
+    }
+    log.Printf("Merged %d records into %s", merged.Table.Len(), *outPath)
+
+    if *reportPath != "" {
+        if conflicts == nil {
+            conflicts = []MergeConflict{}
+        }
+        data, err := json.MarshalIndent(conflicts, "", "  ")
+        if err != nil {
+            log.Fatalf("Failed to encode conflict report: %v", err)
+        }
+        if err := os.WriteFile(*reportPath, data, 0644); err != nil {
+            log.Fatalf("Failed to write %s: %v", *reportPath, err)
+        }
+    }
+
+    if len(conflicts) > 0 {
+        fmt.Printf("%d conflicts, resolved to %s:\n", len(conflicts), *prefer)
+        PrintConflicts(os.Stdout, conflicts)
+        os.Exit(1)
+    }
+}
+
+// loadInputDBC reads the DBC given by --in with the meta of name, or the original
+// from paths.base or the MPQs when inPath is empty
+func loadInputDBC(cfg *Config, name, inPath string) (*DBCFile, *MetaFile, error) {
+    if inPath == "" {
+        return ReadDBCFile(name, cfg)
+    }
+    meta, err := LoadMeta(filepath.Join(cfg.Paths.Meta, name+".meta.json"))
+    if err != nil {
+        return nil, nil, fmt.Errorf("failed to load meta: %w", err)
+    }
+    dbc, err := LoadDBC(inPath, meta)
+    if err != nil {
+        return nil, nil, err
+    }
+    return &dbc, &meta, nil
+}
+
+func handleCSV(cfg *Config, args []string) {
+    if len(args) < 1 || (args[0] != "export" && args[0] != "import") {
+        fmt.Println("Usage: dbctool csv export|import --name=X [options]")
+        return
+    }
+    mode := args[0]
+
+    csvCmd := flag.NewFlagSet("csv "+mode, flag.ExitOnError)
+    dbcName := csvCmd.String("name", "", "DBC file name (without extension)")
+    csvCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    inPath := csvCmd.String("in", "", "Input file (export: the DBC from paths.base or the MPQs, import: <export>/<name>.csv)")
+    csvCmd.StringVar(inPath, "i", "", "Input file (shorthand)")
+    outPath := csvCmd.String("out", "", "Output file (export: <export>/<name>.csv, import: <export>/<file>)")
+    csvCmd.StringVar(outPath, "o", "", "Output file (shorthand)")
+    csvCmd.Parse(args[1:])
+
+    if *dbcName == "" {
+        fmt.Println("Error: --name/-n is required for csv")
+        csvCmd.Usage()
+        return
+    }
+
+    metaPath := filepath.Join(cfg.Paths.Meta, *dbcName+".meta.json")
+    meta, err := LoadMeta(metaPath)
+    if err != nil {
+        log.Fatalf("Failed to load meta: %v", err)
+    }
+    csvPath := filepath.Join(cfg.Paths.Export, strings.TrimSuffix(meta.File, filepath.Ext(meta.File))+".csv")
+    if err := os.MkdirAll(cfg.Paths.Export, 0755); err != nil {
+        log.Fatalf("Failed to create export directory: %v", err)
+    }
+
+    if mode == "export" {
+        dbc, _, err := loadInputDBC(cfg, *dbcName, *inPath)
+        if err != nil {
+            log.Fatalf("Failed to read DBC: %v", err)
+        }
+
+        if *outPath == "" {
+            *outPath = csvPath
+        }
+        f, err := os.Create(*outPath)
+        if err != nil {
+            log.Fatalf("Failed to create %s: %v", *outPath, err)
+        }
+        defer f.Close()
+        if err := WriteCSV(f, dbc); err != nil {
+            log.Fatalf("Failed to write %s: %v", *outPath, err)
+        }
+        log.Printf("Wrote %d records to %s", dbc.Table.Len(), *outPath)
+        return
+    }
+
+    if *inPath == "" {
+        *inPath = csvPath
+    }
+    schema, err := NewSchema(&meta)
+    if err != nil {
+        log.Fatalf("Invalid meta %s: %v", metaPath, err)
+    }
+    f, err := os.Open(*inPath)
+    if err != nil {
+        log.Fatalf("Failed to open %s: %v", *inPath, err)
+    }
+    dbc, err := ReadCSV(f, schema)
+    f.Close()
+    if err != nil {
+        log.Fatalf("Failed to read %s: %v", *inPath, err)
+    }
+
+    // WDB2 header fields and the index come from the original file, if there is one
+    var prev *DBCFile
+    if meta.FileFormat() == FormatWDB2 && dbcExists(cfg, meta.File) {
+        base, err := LoadDBCFile(cfg, meta)
+        if err != nil {
+            log.Fatalf("Failed to read original %s: %v", meta.File, err)
+        }
+        prev = &base
+    }
+    if err := FinishHeader(dbc, prev); err != nil {
+        log.Fatalf("Failed to build header: %v", err)
+    }
+
+    if *outPath == "" {
+        *outPath = filepath.Join(cfg.Paths.Export, meta.File)
+    }
+    if err := WriteDBC(dbc, *outPath); err != nil {
+        log.Fatalf("Failed to write %s: %v", *outPath, err)
+    }
+    log.Printf("Wrote %d records to %s", dbc.Table.Len(), *outPath)
+}
+
+func handleDump(cfg *Config, args []string) {
+    dumpCmd := flag.NewFlagSet("dump", flag.ExitOnError)
+    dbcName := dumpCmd.String("name", "", "DBC file name (without extension)")
+    dumpCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    inPath := dumpCmd.String("in", "", "Input DBC (default: the original from paths.base or the MPQs)")
+    dumpCmd.StringVar(inPath, "i", "", "Input DBC (shorthand)")
+    outPath := dumpCmd.String("out", "", "Output file (default: stdout)")
+    dumpCmd.StringVar(outPath, "o", "", "Output file (shorthand)")
+    ndjson := dumpCmd.Bool("ndjson", false, "Write NDJSON: the header on the first line, then one record per line")
+    dumpCmd.Parse(args)
+
+    if *dbcName == "" {
+        fmt.Println("Error: --name/-n is required for dump")
+        dumpCmd.Usage()
+        return
+    }
+
+    dbc, _, err := loadInputDBC(cfg, *dbcName, *inPath)
+    if err != nil {
+        log.Fatalf("Failed to read DBC: %v", err)
+    }
+
+    out := os.Stdout
+    if *outPath != "" {
+        f, err := os.Create(*outPath)
+        if err != nil {
+            log.Fatalf("Failed to create %s: %v", *outPath, err)
+        }
+        defer f.Close()
+        out = f
+    }
+    if err := WriteJSON(out, dbc, *ndjson); err != nil {
+        log.Fatalf("Failed to write JSON: %v", err)
+    }
+}
+
+func handleLoad(cfg *Config, args []string) {
+    loadCmd := flag.NewFlagSet("load", flag.ExitOnError)
+    dbcName := loadCmd.String("name", "", "DBC file name (without extension)")
+    loadCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    inPath := loadCmd.String("in", "", "JSON or NDJSON file written by dump (default: stdin)")
+    loadCmd.StringVar(inPath, "i", "", "Input file (shorthand)")
+    outPath := loadCmd.String("out", "", "Output DBC (default: the file in the export directory)")
+    loadCmd.StringVar(outPath, "o", "", "Output DBC (shorthand)")
+    loadCmd.Parse(args)
+
+    if *dbcName == "" {
+        fmt.Println("Error: --name/-n is required for load")
+        loadCmd.Usage()
+        return
+    }
+
+    meta, err := LoadMeta(filepath.Join(cfg.Paths.Meta, *dbcName+".meta.json"))
+    if err != nil {
+        log.Fatalf("Failed to load meta: %v", err)
+    }
+    schema, err := NewSchema(&meta)
+    if err != nil {
+        log.Fatalf("Invalid meta: %v", err)
+    }
+
+    in := os.Stdin
+    if *inPath != "" && *inPath != "-" {
+        f, err := os.Open(*inPath)
+        if err != nil {
+            log.Fatalf("Failed to open %s: %v", *inPath, err)
+        }
+        defer f.Close()
+        in = f
+    }
+    dbc, err := ReadJSON(in, schema)
+    if err != nil {
+        log.Fatalf("Failed to load JSON: %v", err)
+    }
+
+    if *outPath == "" {
+        if err := os.MkdirAll(cfg.Paths.Export, 0755); err != nil {
+            log.Fatalf("Failed to create export directory: %v", err)
+        }
+        *outPath = filepath.Join(cfg.Paths.Export, meta.File)
+    }
+    if err := WriteDBC(dbc, *outPath); err != nil {
+        log.Fatalf("Failed to write %s: %v", *outPath, err)
+    }
+    log.Printf("Wrote %d records to %s", dbc.Table.Len(), *outPath)
+}
+
+func handleRecords(cfg *Config, args []string) {
+    if len(args) < 1 || (args[0] != "export" && args[0] != "build") {
+        fmt.Println("Usage: dbctool records export|build --name=X [options]")
+        return
+    }
+    mode := args[0]
+
+    recordsCmd := flag.NewFlagSet("records "+mode, flag.ExitOnError)
+    dbcName := recordsCmd.String("name", "", "DBC file name (without extension)")
+    recordsCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    inPath := recordsCmd.String("in", "", "Input (export: the DBC from paths.base or the MPQs, build: <export>/<name>/)")
+    recordsCmd.StringVar(inPath, "i", "", "Input (shorthand)")
+    outPath := recordsCmd.String("out", "", "Output (export: <export>/<name>/, build: <export>/<file>)")
+    recordsCmd.StringVar(outPath, "o", "", "Output (shorthand)")
+    recordsCmd.Parse(args[1:])
+
+    if *dbcName == "" {
+        fmt.Println("Error: --name/-n is required for records")
+        recordsCmd.Usage()
+        return
+    }
+
+    metaPath := filepath.Join(cfg.Paths.Meta, *dbcName+".meta.json")
+    meta, err := LoadMeta(metaPath)
+    if err != nil {
+        log.Fatalf("Failed to load meta: %v", err)
+    }
+    recordDir := filepath.Join(cfg.Paths.Export, strings.TrimSuffix(meta.File, filepath.Ext(meta.File)))
+
+    if mode == "export" {
+        dbc, _, err := loadInputDBC(cfg, *dbcName, *inPath)
+        if err != nil {
+            log.Fatalf("Failed to read DBC: %v", err)
+        }
+        if *outPath == "" {
+            *outPath = recordDir
+        }
+        n, err := WriteRecordFiles(*outPath, dbc)
+        if err != nil {
+            log.Fatalf("Failed to write records to %s: %v", *outPath, err)
+        }
+        log.Printf("Wrote %d record files to %s", n, *outPath)
+        return
+    }
+
+    if *inPath == "" {
+        *inPath = recordDir
+    }
+    schema, err := NewSchema(&meta)
+    if err != nil {
+        log.Fatalf("Invalid meta %s: %v", metaPath, err)
+    }
+    dbc, err := ReadRecordFiles(*inPath, schema)
+    if err != nil {
+        log.Fatalf("Failed to read records from %s: %v", *inPath, err)
+    }
+    if *outPath == "" {
+        *outPath = filepath.Join(cfg.Paths.Export, meta.File)
+    }
+    if err := WriteDBC(dbc, *outPath); err != nil {
+        log.Fatalf("Failed to write %s: %v", *outPath, err)
+    }
+    log.Printf("Wrote %d records to %s", dbc.Table.Len(), *outPath)
+}
+
+// stringList is a flag that may be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+    return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+    *l = append(*l, v)
+    return nil
+}
+
+func handleEdit(cfg *Config, args []string) {
+    editCmd := flag.NewFlagSet("edit", flag.ExitOnError)
+    dbcName := editCmd.String("name", "", "DBC file name (without extension)")
+    editCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    key := editCmd.String("key", "", "Primary key of the record to edit (composite keys joined with ':')")
+    editCmd.StringVar(key, "k", "", "Primary key (shorthand)")
+    var sets stringList
+    editCmd.Var(&sets, "set", "Assignment column=value (repeatable)")
+    editCmd.Var(&sets, "s", "Assignment (shorthand)")
+    editFile := editCmd.String("file", "", "File of batch edits, one '<key> column=value ...' line per record")
+    editCmd.StringVar(editFile, "f", "", "Batch edit file (shorthand)")
+    inPath := editCmd.String("in", "", "Input DBC (default: the original from paths.base or the MPQs)")
+    editCmd.StringVar(inPath, "i", "", "Input DBC (shorthand)")
+    outPath := editCmd.String("out", "", "Output DBC (default: <export>/<file>)")
+    editCmd.StringVar(outPath, "o", "", "Output DBC (shorthand)")
+    dryRun := editCmd.Bool("dry-run", false, "Print the changes without writing the DBC")
+    editCmd.Parse(args)
+
+    if *dbcName == "" {
+        fmt.Println("Error: --name/-n is required for edit")
+        editCmd.Usage()
+        return
+    }
+    if (*key == "") != (len(sets) == 0) {
+        fmt.Println("Error: --key/-k and --set/-s must be given together")
+        editCmd.Usage()
+        return
+    }
+    if *key == "" && *editFile == "" {
+        fmt.Println("Error: --key/-k with --set/-s, or --file/-f, is required for edit")
+        editCmd.Usage()
+        return
+    }
+
+    dbc, meta, err := loadInputDBC(cfg, *dbcName, *inPath)
+    if err != nil {
+        log.Fatalf("Failed to read DBC: %v", err)
+    }
+
+    var edits []Edit
+    if *editFile != "" {
+        f, err := os.Open(*editFile)
+        if err != nil {
+            log.Fatalf("Failed to open %s: %v", *editFile, err)
+        }
+        edits, err = ReadEdits(f)
+        f.Close()
+        if err != nil {
+            log.Fatalf("Failed to read %s: %v", *editFile, err)
+        }
+    }
+    if *key != "" {
+        edits = append(edits, Edit{Key: *key, Sets: sets})
+    }
+
+    changes, err := ApplyEdits(dbc, edits)
+    if err != nil {
+        log.Fatalf("Failed to edit %s: %v", meta.File, err)
+    }
+    for _, c := range changes {
+        fmt.Printf("%s: %s %s -> %s\n", c.Key, c.Column, c.Old, c.New)
+    }
+    if *dryRun {
+        return
+    }
+
+    if *outPath == "" {
+        *outPath = filepath.Join(cfg.Paths.Export, meta.File)
+    }
+    if err := WriteDBC(dbc, *outPath); err != nil {
+        log.Fatalf("Failed to write %s: %v", *outPath, err)
+    }
+    log.Printf("Applied %d changes, wrote %s", len(changes), *outPath)
+}
+
+func handleQuery(cfg *Config, args []string) {
+    queryCmd := flag.NewFlagSet("query", flag.ExitOnError)
+    dbcName := queryCmd.String("name", "", "DBC file name (without extension)")
+    queryCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    where := queryCmd.String("where", "", "Filter expression, e.g. 'school_mask & 4 && spell_name ~ \"Fire\"'")
+    queryCmd.StringVar(where, "w", "", "Filter expression (shorthand)")
+    selectCols := queryCmd.String("select", "", "Comma-separated columns to print (default: all)")
+    queryCmd.StringVar(selectCols, "s", "", "Columns to print (shorthand)")
+    inPath := queryCmd.String("in", "", "Input DBC (default: the original from paths.base or the MPQs)")
+    queryCmd.StringVar(inPath, "i", "", "Input DBC (shorthand)")
+    format := queryCmd.String("format", "text", "Output format: text, csv or ndjson")
+    limit := queryCmd.Int("limit", 0, "Stop after this many matches (0: no limit)")
+    queryCmd.Parse(args)
+
+    if *dbcName == "" {
+        fmt.Println("Error: --name/-n is required for query")
+        queryCmd.Usage()
+        return
+    }
+    if *format != "text" && *format != "csv" && *format != "ndjson" {
+        log.Fatalf("Unknown format %q, expected text, csv or ndjson", *format)
+    }
+
+    dbc, _, err := loadInputDBC(cfg, *dbcName, *inPath)
+    if err != nil {
+        log.Fatalf("Failed to read DBC: %v", err)
+    }
+    schema := dbc.Table.Schema
+
+    var q *Query
+    if *where != "" {
+        if q, err = CompileQuery(schema, *where); err != nil {
+            log.Fatalf("Invalid --where: %v", err)
+        }
+    }
+
+    var cols []int
+    if *selectCols != "" {
+        if cols, err = SelectColumns(schema, *selectCols); err != nil {
+            log.Fatalf("Invalid --select: %v", err)
+        }
+    } else {
+        for c := range schema.Columns {
+            cols = append(cols, c)
+        }
+    }
+
+    rows, err := Filter(dbc, q, *limit)
+    if err != nil {
+        log.Fatalf("Query failed: %v", err)
+    }
+    if err := WriteQueryResult(os.Stdout, dbc, rows, cols, *format); err != nil {
+        log.Fatalf("Failed to write result: %v", err)
+    }
+}
+
+func handleGrep(cfg *Config, args []string) {
+    grepCmd := flag.NewFlagSet("grep", flag.ExitOnError)
+    value := grepCmd.String("value", "", "Value to search for")
+    grepCmd.StringVar(value, "v", "", "Value to search for (shorthand)")
+    typ := grepCmd.String("type", "", "Only search columns of this type, e.g. uint32 or string (default: all types the value parses as)")
+    grepCmd.StringVar(typ, "t", "", "Column type (shorthand)")
+    substring := grepCmd.Bool("substring", false, "Match strings containing the value instead of equal to it")
+    grepCmd.Parse(args)
+
+    if *value == "" {
+        fmt.Println("Error: --value/-v is required for grep")
+        grepCmd.Usage()
+        return
+    }
+
+    matches, err := GrepDBCs(cfg, GrepOptions{Value: *value, Type: *typ, Substring: *substring})
+    if err != nil {
+        log.Fatalf("Search failed: %v", err)
+    }
+
+    tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+    tables := map[string]bool{}
+    for _, m := range matches {
+        fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.File, m.Key, m.Column, m.Text)
+        tables[m.File] = true
+    }
+    tw.Flush()
+    fmt.Printf("%d matches in %d tables\n", len(matches), len(tables))
+}
+
+func handleCheckRefs(cfg *Config, args []string) {
+    refsCmd := flag.NewFlagSet("check-refs", flag.ExitOnError)
+    dbcName := refsCmd.String("name", "", "Only check the references of this DBC")
+    refsCmd.StringVar(dbcName, "n", "", "DBC file name (shorthand)")
+    useDB := refsCmd.Bool("db", false, "Check the imported tables instead of the DBC files")
+    refsCmd.Parse(args)
+
+    metas := []string{}
+    if *dbcName == "" {
+        all, err := filepath.Glob(filepath.Join(cfg.Paths.Meta, "*.meta.json"))
+        if err != nil {
+            log.Fatalf("Failed to scan meta directory: %v", err)
+        }
+        metas = all
+    } else {
+        metas = []string{filepath.Join(cfg.Paths.Meta, *dbcName+".meta.json")}
+    }
+
+    var dangling []DanglingRef
+    var err error
+    if *useDB {
+        dbcDB, dbErr := openDB(cfg.DBC, false)
+        if dbErr != nil {
+            log.Fatalf("Failed to connect to DBC DB: %v", dbErr)
+        }
+        defer dbcDB.Close()
+        dangling, err = CheckRefsDB(dbcDB, cfg, metas)
+    } else {
+        dangling, err = CheckRefsDBC(cfg, metas)
+    }
+    if err != nil {
+        log.Fatalf("Reference check failed: %v", err)
+    }
+
+    tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+    tables := map[string]bool{}
+    for _, r := range dangling {
+        fmt.Fprintf(tw, "%s\t%s\t%s = %d\t-> %s not found\n", r.Table, r.Key, r.Column, r.Value, r.Target)
+        tables[r.Table] = true
+    }
+    tw.Flush()
+    log.Printf("Reference check complete: %d dangling references in %d tables", len(dangling), len(tables))
+    if len(dangling) > 0 {
+        os.Exit(1)
+    }
+}
+
 func printUsage() {
     fmt.Println("Usage: dbcreader <command> [options]")
     fmt.Println("Commands:")
@@ -279,5 +1205,18 @@ func printUsage() {
     fmt.Println("  import  - Import DBC files into the database")
     fmt.Println("  export  - Export database tables back to DBC files")
     fmt.Println("  verify  - Compare original and exported DBC files for 1:1 match")
+    fmt.Println("  infer   - Guess a draft meta file for a DBC without one")
+    fmt.Println("  lint    - Check meta files for mistakes and against their DBC headers")
+    fmt.Println("  dbd     - Generate a meta file from a WoWDBDefs .dbd definition")
+    fmt.Println("  diff    - Compare two DBC files record by record")
+    fmt.Println("  merge   - Three-way merge of DBC files or MySQL schemas")
+    fmt.Println("  csv     - Convert a DBC to CSV (csv export) or back (csv import)")
+    fmt.Println("  dump    - Write a whole DBC as JSON or NDJSON")
+    fmt.Println("  load    - Rebuild a DBC from JSON or NDJSON written by dump")
+    fmt.Println("  records - Split a DBC into one JSON file per record (records export) or back (records build)")
+    fmt.Println("  edit    - Change fields of records by primary key and write the DBC")
+    fmt.Println("  query   - List the records of a DBC that match a filter expression")
+    fmt.Println("  grep    - Find every table, record and field holding a value")
+    fmt.Println("  check-refs - Report references to ids missing from the referenced table")
     fmt.Println("\nUse 'dbcreader <command> -h' for command-specific options")
 }
\ No newline at end of file
@@ -0,0 +1,206 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "fmt"
+    "io"
+)
+
+// MergeConflict is a change made differently on both sides. The merged file
+// contains the preferred side's version.
+type MergeConflict struct {
+    Key    string `json:"key"`
+    Kind   string `json:"kind"` // field, modify/delete or add/add
+    Field  string `json:"field,omitempty"`
+    Base   string `json:"base,omitempty"`
+    Ours   string `json:"ours,omitempty"`
+    Theirs string `json:"theirs,omitempty"`
+}
+
+// mergeSide is one input of a merge with its records indexed by key
+type mergeSide struct {
+    dbc  *DBCFile
+    keys []string
+    rows map[string]int
+}
+
+func newMergeSide(dbc *DBCFile, keyCols []int) mergeSide {
+    s := mergeSide{dbc: dbc, keys: recordKeys(dbc, keyCols), rows: map[string]int{}}
+    for row, key := range s.keys {
+        s.rows[key] = row
+    }
+    return s
+}
+
+// MergeDBC merges the changes ours and theirs made to base, matching records by
+// primary key. Records and fields changed on one side only are taken from that
+// side; conflicting changes resolve to the side named by prefer ("ours" or
+// "theirs") and are returned for review. Records keep the order of ours, with
+// records added only by theirs appended.
+func MergeDBC(base, ours, theirs *DBCFile, prefer string) (*DBCFile, []MergeConflict, error) {
+    schema := ours.Table.Schema
+    for name, other := range map[string]*DBCFile{"base": base, "theirs": theirs} {
+        if len(other.Table.Schema.Columns) != len(schema.Columns) {
+            return nil, nil, fmt.Errorf("%s has %d columns, ours has %d", name, len(other.Table.Schema.Columns), len(schema.Columns))
+        }
+    }
+    if prefer != "ours" && prefer != "theirs" {
+        return nil, nil, fmt.Errorf("unknown side %q, expected ours or theirs", prefer)
+    }
+
+    keyCols := keyColumns(schema)
+    if len(keyCols) == 0 {
+        return nil, nil, fmt.Errorf("meta has no primary key to match records by")
+    }
+    b := newMergeSide(base, keyCols)
+    o := newMergeSide(ours, keyCols)
+    t := newMergeSide(theirs, keyCols)
+
+    merged := &DBCFile{Header: DBCHeader{Magic: ours.Header.Magic}}
+    merged.Table = NewTable(schema, ours.Table.Len())
+    merged.StringBlock = []byte{0}
+    offsets := map[string]uint32{"": 0}
+
+    var conflicts []MergeConflict
+    conflict := func(c MergeConflict) {
+        conflicts = append(conflicts, c)
+    }
+
+    // appendRow copies a source row, or merges the fields of both sides when src is nil
+    appendRow := func(src *DBCFile, row int, merge func(c int) (*DBCFile, int)) {
+        out := merged.Table.AppendRow()
+        for c, col := range schema.Columns {
+            from, fromRow := src, row
+            if merge != nil {
+                from, fromRow = merge(c)
+            }
+            if col.Type == "string" {
+                merged.Table.SetRaw(out, c, uint64(getStringOffset(from.Text(fromRow, c), &merged.StringBlock, offsets)))
+            } else {
+                merged.Table.SetRaw(out, c, from.Table.Raw(fromRow, c))
+            }
+        }
+    }
+
+    // order: ours, then what only theirs added
+    order := append([]string{}, o.keys...)
+    for _, key := range t.keys {
+        if _, ok := o.rows[key]; !ok {
+            if _, inBase := b.rows[key]; !inBase {
+                order = append(order, key)
+            }
+        }
+    }
+    // records ours deleted but theirs modified are kept when preferring theirs
+    for _, key := range t.keys {
+        _, inOurs := o.rows[key]
+        br, inBase := b.rows[key]
+        if !inOurs && inBase && !rowsEqual(base, br, theirs, t.rows[key]) {
+            order = append(order, key)
+        }
+    }
+
+    for _, key := range order {
+        br, inBase := b.rows[key]
+        or, inOurs := o.rows[key]
+        tr, inTheirs := t.rows[key]
+
+        switch {
+        case inOurs && inTheirs && inBase:
+            appendRow(nil, 0, func(c int) (*DBCFile, int) {
+                oursSame := cellEqual(base, br, ours, or, c)
+                theirsSame := cellEqual(base, br, theirs, tr, c)
+                switch {
+                case oursSame:
+                    return theirs, tr
+                case theirsSame, cellEqual(ours, or, theirs, tr, c):
+                    return ours, or
+                }
+                conflict(MergeConflict{
+                    Key: key, Kind: "field", Field: schema.Columns[c].Name,
+                    Base: formatValue(base, br, c), Ours: formatValue(ours, or, c), Theirs: formatValue(theirs, tr, c),
+                })
+                if prefer == "theirs" {
+                    return theirs, tr
+                }
+                return ours, or
+            })
+
+        case inOurs && inTheirs:
+            // added on both sides
+            if !rowsEqual(ours, or, theirs, tr) {
+                conflict(MergeConflict{Key: key, Kind: "add/add"})
+                if prefer == "theirs" {
+                    appendRow(theirs, tr, nil)
+                    continue
+                }
+            }
+            appendRow(ours, or, nil)
+
+        case inOurs && inBase:
+            // theirs deleted it
+            if rowsEqual(base, br, ours, or) {
+                continue
+            }
+            conflict(MergeConflict{Key: key, Kind: "modify/delete", Ours: "modified", Theirs: "deleted"})
+            if prefer == "ours" {
+                appendRow(ours, or, nil)
+            }
+
+        case inOurs:
+            appendRow(ours, or, nil)
+
+        case inTheirs && inBase:
+            // ours deleted it, theirs modified it
+            conflict(MergeConflict{Key: key, Kind: "modify/delete", Ours: "deleted", Theirs: "modified"})
+            if prefer == "theirs" {
+                appendRow(theirs, tr, nil)
+            }
+
+        case inTheirs:
+            appendRow(theirs, tr, nil)
+        }
+    }
+
+    if err := FinishHeader(merged, ours); err != nil {
+        return nil, nil, err
+    }
+
+    return merged, conflicts, nil
+}
+
+// cellEqual compares one column of two rows, strings by text
+func cellEqual(a *DBCFile, ar int, b *DBCFile, br int, c int) bool {
+    if a.Table.Schema.Columns[c].Type == "string" {
+        return a.Text(ar, c) == b.Text(br, c)
+    }
+    return a.Table.Raw(ar, c) == b.Table.Raw(br, c)
+}
+
+// rowsEqual compares all columns of two rows
+func rowsEqual(a *DBCFile, ar int, b *DBCFile, br int) bool {
+    for c := range a.Table.Schema.Columns {
+        if !cellEqual(a, ar, b, br, c) {
+            return false
+        }
+    }
+    return true
+}
+
+// PrintConflicts writes a human readable conflict report
+func PrintConflicts(w io.Writer, conflicts []MergeConflict) {
+    for _, c := range conflicts {
+        switch c.Kind {
+        case "field":
+            fmt.Fprintf(w, "  ! record %s field %s: base %s, ours %s, theirs %s\n", c.Key, c.Field, c.Base, c.Ours, c.Theirs)
+        case "add/add":
+            fmt.Fprintf(w, "  ! record %s added differently on both sides\n", c.Key)
+        default:
+            fmt.Fprintf(w, "  ! record %s %s by ours, %s by theirs\n", c.Key, c.Ours, c.Theirs)
+        }
+    }
+}
@@ -0,0 +1,480 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "compress/bzip2"
+    "compress/zlib"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+const (
+    mpqMagic         = "MPQ\x1a"
+    mpqUserDataMagic = "MPQ\x1b"
+
+    mpqHashEmpty   = 0xFFFFFFFF
+    mpqHashDeleted = 0xFFFFFFFE
+
+    mpqFileImplode      = 0x00000100
+    mpqFileCompress     = 0x00000200
+    mpqFileEncrypted    = 0x00010000
+    mpqFileFixKey       = 0x00020000
+    mpqFilePatchFile    = 0x00100000
+    mpqFileSingleUnit   = 0x01000000
+    mpqFileDeleteMarker = 0x02000000
+    mpqFileSectorCRC    = 0x04000000
+    mpqFileExists       = 0x80000000
+
+    mpqCompressZlib  = 0x02
+    mpqCompressBzip2 = 0x10
+
+    mpqDBCDir = "DBFilesClient\\"
+)
+
+// mpqCryptTable is the shared table used by the MPQ hash and encryption functions
+var mpqCryptTable = func() [0x500]uint32 {
+    var table [0x500]uint32
+    seed := uint32(0x00100001)
+    for i := 0; i < 0x100; i++ {
+        for j := i; j < 0x500; j += 0x100 {
+            seed = (seed*125 + 3) % 0x2AAAAB
+            hi := (seed & 0xFFFF) << 16
+            seed = (seed*125 + 3) % 0x2AAAAB
+            table[j] = hi | (seed & 0xFFFF)
+        }
+    }
+    return table
+}()
+
+// mpqHash hashes a file name; hashType 0 is the table offset, 1 and 2 the
+// name checks and 3 the encryption key
+func mpqHash(name string, hashType uint32) uint32 {
+    seed1 := uint32(0x7FED7FED)
+    seed2 := uint32(0xEEEEEEEE)
+    for _, c := range []byte(strings.ToUpper(strings.ReplaceAll(name, "/", "\\"))) {
+        seed1 = mpqCryptTable[hashType*0x100+uint32(c)] ^ (seed1 + seed2)
+        seed2 = uint32(c) + seed1 + seed2 + (seed2 << 5) + 3
+    }
+    return seed1
+}
+
+// mpqDecrypt decrypts data in place, in whole 4-byte blocks
+func mpqDecrypt(data []byte, key uint32) {
+    seed := uint32(0xEEEEEEEE)
+    for i := 0; i+4 <= len(data); i += 4 {
+        seed += mpqCryptTable[0x400+(key&0xFF)]
+        v := binary.LittleEndian.Uint32(data[i:]) ^ (key + seed)
+        key = ((^key << 21) + 0x11111111) | (key >> 11)
+        seed = v + seed + (seed << 5) + 3
+        binary.LittleEndian.PutUint32(data[i:], v)
+    }
+}
+
+type mpqHeader struct {
+    HeaderSize      uint32
+    ArchiveSize     uint32
+    FormatVersion   uint16
+    SectorSizeShift uint16
+    HashTablePos    uint64
+    BlockTablePos   uint64
+    HashTableSize   uint32
+    BlockTableSize  uint32
+}
+
+type mpqHashEntry struct {
+    HashA      uint32
+    HashB      uint32
+    Locale     uint16
+    Platform   uint16
+    BlockIndex uint32
+}
+
+type mpqBlockEntry struct {
+    FilePos        uint64
+    CompressedSize uint32
+    FileSize       uint32
+    Flags          uint32
+}
+
+// MPQArchive is an open MPQ archive
+type MPQArchive struct {
+    Path   string
+    file   *os.File
+    offset int64 // archive start within the file
+    header mpqHeader
+    hashes []mpqHashEntry
+    blocks []mpqBlockEntry
+}
+
+// OpenMPQ opens an archive and reads its hash and block tables
+func OpenMPQ(path string) (*MPQArchive, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    a := &MPQArchive{Path: path, file: f}
+    if err := a.readTables(); err != nil {
+        f.Close()
+        return nil, fmt.Errorf("failed to read MPQ %s: %w", path, err)
+    }
+    return a, nil
+}
+
+// Close closes the underlying file
+func (a *MPQArchive) Close() error {
+    return a.file.Close()
+}
+
+func (a *MPQArchive) readTables() error {
+    // the header sits on a 512-byte boundary, optionally behind a user data block
+    buf := make([]byte, 44)
+    for off := int64(0); ; off += 512 {
+        n, err := a.file.ReadAt(buf, off)
+        if n < 32 {
+            if err == nil || err == io.EOF {
+                return fmt.Errorf("no MPQ header found")
+            }
+            return err
+        }
+        magic := string(buf[:4])
+        if magic == mpqUserDataMagic {
+            headerOffset := int64(binary.LittleEndian.Uint32(buf[8:12]))
+            if n, err = a.file.ReadAt(buf, off+headerOffset); n < 32 {
+                return fmt.Errorf("truncated MPQ header: %v", err)
+            }
+            off += headerOffset
+            magic = string(buf[:4])
+        }
+        if magic == mpqMagic {
+            a.offset = off
+            break
+        }
+    }
+
+    h := &a.header
+    h.HeaderSize = binary.LittleEndian.Uint32(buf[4:8])
+    h.ArchiveSize = binary.LittleEndian.Uint32(buf[8:12])
+    h.FormatVersion = binary.LittleEndian.Uint16(buf[12:14])
+    h.SectorSizeShift = binary.LittleEndian.Uint16(buf[14:16])
+    h.HashTablePos = uint64(binary.LittleEndian.Uint32(buf[16:20]))
+    h.BlockTablePos = uint64(binary.LittleEndian.Uint32(buf[20:24]))
+    h.HashTableSize = binary.LittleEndian.Uint32(buf[24:28])
+    h.BlockTableSize = binary.LittleEndian.Uint32(buf[28:32])
+
+    var hiBlockTablePos uint64
+    if h.FormatVersion >= 1 && h.HeaderSize >= 44 {
+        hiBlockTablePos = binary.LittleEndian.Uint64(buf[32:40])
+        h.HashTablePos |= uint64(binary.LittleEndian.Uint16(buf[40:42])) << 32
+        h.BlockTablePos |= uint64(binary.LittleEndian.Uint16(buf[42:44])) << 32
+    }
+
+    hashData, err := a.readTable(h.HashTablePos, h.HashTableSize, "(hash table)")
+    if err != nil {
+        return fmt.Errorf("hash table: %w", err)
+    }
+    a.hashes = make([]mpqHashEntry, h.HashTableSize)
+    for i := range a.hashes {
+        e := hashData[i*16:]
+        a.hashes[i] = mpqHashEntry{
+            HashA:      binary.LittleEndian.Uint32(e[0:4]),
+            HashB:      binary.LittleEndian.Uint32(e[4:8]),
+            Locale:     binary.LittleEndian.Uint16(e[8:10]),
+            Platform:   binary.LittleEndian.Uint16(e[10:12]),
+            BlockIndex: binary.LittleEndian.Uint32(e[12:16]),
+        }
+    }
+
+    blockData, err := a.readTable(h.BlockTablePos, h.BlockTableSize, "(block table)")
+    if err != nil {
+        return fmt.Errorf("block table: %w", err)
+    }
+    a.blocks = make([]mpqBlockEntry, h.BlockTableSize)
+    for i := range a.blocks {
+        e := blockData[i*16:]
+        a.blocks[i] = mpqBlockEntry{
+            FilePos:        uint64(binary.LittleEndian.Uint32(e[0:4])),
+            CompressedSize: binary.LittleEndian.Uint32(e[4:8]),
+            FileSize:       binary.LittleEndian.Uint32(e[8:12]),
+            Flags:          binary.LittleEndian.Uint32(e[12:16]),
+        }
+    }
+
+    if hiBlockTablePos != 0 {
+        hi := make([]byte, len(a.blocks)*2)
+        if _, err := a.file.ReadAt(hi, a.offset+int64(hiBlockTablePos)); err != nil {
+            return fmt.Errorf("hi-block table: %w", err)
+        }
+        for i := range a.blocks {
+            a.blocks[i].FilePos |= uint64(binary.LittleEndian.Uint16(hi[i*2:])) << 32
+        }
+    }
+
+    return nil
+}
+
+// readTable reads and decrypts a hash or block table of 16-byte entries
+func (a *MPQArchive) readTable(pos uint64, entries uint32, key string) ([]byte, error) {
+    data := make([]byte, int(entries)*16)
+    if _, err := a.file.ReadAt(data, a.offset+int64(pos)); err != nil {
+        return nil, err
+    }
+    mpqDecrypt(data, mpqHash(key, 3))
+    return data, nil
+}
+
+// findEntry returns the hash entry for a file name, preferring the neutral locale
+func (a *MPQArchive) findEntry(name string) (mpqHashEntry, bool) {
+    size := uint32(len(a.hashes))
+    if size == 0 {
+        return mpqHashEntry{}, false
+    }
+    start := mpqHash(name, 0) % size
+    hashA := mpqHash(name, 1)
+    hashB := mpqHash(name, 2)
+
+    var found mpqHashEntry
+    ok := false
+    for i := uint32(0); i < size; i++ {
+        e := a.hashes[(start+i)%size]
+        if e.BlockIndex == mpqHashEmpty {
+            break
+        }
+        if e.BlockIndex == mpqHashDeleted || e.HashA != hashA || e.HashB != hashB {
+            continue
+        }
+        if !ok || e.Locale == 0 {
+            found, ok = e, true
+        }
+        if e.Locale == 0 {
+            break
+        }
+    }
+    return found, ok
+}
+
+// lookup returns the block of a file and whether it exists; delete markers are returned as existing
+func (a *MPQArchive) lookup(name string) (mpqBlockEntry, bool) {
+    e, ok := a.findEntry(name)
+    if !ok || e.BlockIndex >= uint32(len(a.blocks)) {
+        return mpqBlockEntry{}, false
+    }
+    block := a.blocks[e.BlockIndex]
+    return block, block.Flags&mpqFileExists != 0
+}
+
+// Has reports whether the archive holds a file
+func (a *MPQArchive) Has(name string) bool {
+    block, ok := a.lookup(name)
+    return ok && block.Flags&mpqFileDeleteMarker == 0
+}
+
+// ReadFile extracts a file from the archive
+func (a *MPQArchive) ReadFile(name string) ([]byte, error) {
+    block, ok := a.lookup(name)
+    if !ok || block.Flags&mpqFileDeleteMarker != 0 {
+        return nil, fmt.Errorf("%s not found in %s", name, a.Path)
+    }
+    if block.Flags&mpqFilePatchFile != 0 {
+        return nil, fmt.Errorf("%s in %s is an incremental patch file, which is not supported", name, a.Path)
+    }
+    if block.Flags&mpqFileImplode != 0 {
+        return nil, fmt.Errorf("%s in %s uses PKWARE implode compression, which is not supported", name, a.Path)
+    }
+
+    raw := make([]byte, block.CompressedSize)
+    if _, err := a.file.ReadAt(raw, a.offset+int64(block.FilePos)); err != nil {
+        return nil, fmt.Errorf("failed to read %s from %s: %w", name, a.Path, err)
+    }
+
+    var key uint32
+    if block.Flags&mpqFileEncrypted != 0 {
+        base := name[strings.LastIndexAny(name, "\\/")+1:]
+        key = mpqHash(base, 3)
+        if block.Flags&mpqFileFixKey != 0 {
+            key = (key + uint32(block.FilePos)) ^ block.FileSize
+        }
+    }
+
+    compressed := block.Flags&mpqFileCompress != 0
+    if block.Flags&mpqFileSingleUnit != 0 {
+        if key != 0 {
+            mpqDecrypt(raw, key)
+        }
+        if compressed && block.CompressedSize < block.FileSize {
+            return mpqDecompress(raw, int(block.FileSize))
+        }
+        return raw[:block.FileSize], nil
+    }
+
+    sectorSize := 512 << a.header.SectorSizeShift
+    sectorCount := (int(block.FileSize) + sectorSize - 1) / sectorSize
+
+    // uncompressed files have implicit sector boundaries
+    offsets := make([]uint32, sectorCount+1)
+    if compressed {
+        tableSize := (sectorCount + 1) * 4
+        if block.Flags&mpqFileSectorCRC != 0 {
+            tableSize += 4
+        }
+        if len(raw) < tableSize {
+            return nil, fmt.Errorf("truncated sector table for %s in %s", name, a.Path)
+        }
+        table := append([]byte(nil), raw[:tableSize]...)
+        if key != 0 {
+            mpqDecrypt(table, key-1)
+        }
+        for i := range offsets {
+            offsets[i] = binary.LittleEndian.Uint32(table[i*4:])
+        }
+    } else {
+        for i := range offsets {
+            offsets[i] = uint32(i * sectorSize)
+        }
+        offsets[sectorCount] = block.FileSize
+    }
+
+    out := make([]byte, 0, block.FileSize)
+    for i := 0; i < sectorCount; i++ {
+        start, end := offsets[i], offsets[i+1]
+        if end < start || int(end) > len(raw) {
+            return nil, fmt.Errorf("corrupt sector %d of %s in %s", i, name, a.Path)
+        }
+        sector := append([]byte(nil), raw[start:end]...)
+        if key != 0 {
+            mpqDecrypt(sector, key+uint32(i))
+        }
+
+        want := sectorSize
+        if rest := int(block.FileSize) - len(out); rest < want {
+            want = rest
+        }
+        if compressed && len(sector) < want {
+            data, err := mpqDecompress(sector, want)
+            if err != nil {
+                return nil, fmt.Errorf("sector %d of %s in %s: %w", i, name, a.Path, err)
+            }
+            sector = data
+        }
+        if len(sector) > want {
+            sector = sector[:want]
+        }
+        out = append(out, sector...)
+    }
+
+    if len(out) != int(block.FileSize) {
+        return nil, fmt.Errorf("%s in %s: extracted %d bytes, expected %d", name, a.Path, len(out), block.FileSize)
+    }
+    return out, nil
+}
+
+// mpqDecompress inflates a sector whose first byte is the compression mask
+func mpqDecompress(data []byte, size int) ([]byte, error) {
+    if len(data) == 0 {
+        return nil, fmt.Errorf("empty compressed sector")
+    }
+    mask, payload := data[0], data[1:]
+
+    var r io.Reader
+    switch mask {
+    case mpqCompressZlib:
+        zr, err := zlib.NewReader(bytes.NewReader(payload))
+        if err != nil {
+            return nil, fmt.Errorf("zlib: %w", err)
+        }
+        defer zr.Close()
+        r = zr
+    case mpqCompressBzip2:
+        r = bzip2.NewReader(bytes.NewReader(payload))
+    default:
+        return nil, fmt.Errorf("unsupported compression mask 0x%02X", mask)
+    }
+
+    out := make([]byte, size)
+    if _, err := io.ReadFull(r, out); err != nil {
+        return nil, fmt.Errorf("decompress: %w", err)
+    }
+    return out, nil
+}
+
+// MPQSet is a patch chain of archives, ordered from lowest to highest precedence
+type MPQSet struct {
+    Archives []*MPQArchive
+}
+
+// OpenMPQSet opens every archive matched by the patterns. Patterns are expanded
+// in order; matches of one glob are sorted so that patch.MPQ precedes patch-2.MPQ.
+func OpenMPQSet(patterns []string) (*MPQSet, error) {
+    set := &MPQSet{}
+    seen := map[string]bool{}
+    for _, pattern := range patterns {
+        matches, err := filepath.Glob(pattern)
+        if err != nil {
+            return nil, fmt.Errorf("bad MPQ pattern %s: %w", pattern, err)
+        }
+        sort.Slice(matches, func(i, j int) bool {
+            a := strings.ToLower(strings.TrimSuffix(matches[i], filepath.Ext(matches[i])))
+            b := strings.ToLower(strings.TrimSuffix(matches[j], filepath.Ext(matches[j])))
+            return a < b
+        })
+        for _, path := range matches {
+            if seen[path] {
+                continue
+            }
+            seen[path] = true
+            archive, err := OpenMPQ(path)
+            if err != nil {
+                set.Close()
+                return nil, err
+            }
+            set.Archives = append(set.Archives, archive)
+        }
+    }
+    return set, nil
+}
+
+// find returns the highest-precedence archive holding a file, honouring delete markers
+func (s *MPQSet) find(name string) *MPQArchive {
+    for i := len(s.Archives) - 1; i >= 0; i-- {
+        block, ok := s.Archives[i].lookup(name)
+        if !ok {
+            continue
+        }
+        if block.Flags&mpqFileDeleteMarker != 0 {
+            return nil
+        }
+        return s.Archives[i]
+    }
+    return nil
+}
+
+// Has reports whether any archive in the chain provides a file
+func (s *MPQSet) Has(name string) bool {
+    return s.find(name) != nil
+}
+
+// ReadFile extracts a file from the highest-precedence archive holding it
+func (s *MPQSet) ReadFile(name string) ([]byte, string, error) {
+    archive := s.find(name)
+    if archive == nil {
+        return nil, "", fmt.Errorf("%s not found in MPQ archives", name)
+    }
+    data, err := archive.ReadFile(name)
+    return data, archive.Path, err
+}
+
+// Close closes all archives
+func (s *MPQSet) Close() {
+    for _, a := range s.Archives {
+        a.Close()
+    }
+}
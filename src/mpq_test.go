@@ -0,0 +1,274 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "compress/zlib"
+    "encoding/binary"
+    "encoding/hex"
+    "math/rand"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// bzip2 of "single unit bzip2 file\n" repeated 40 times; the standard library has no bzip2 writer
+const testBzip2Hex = "425a6839314159265359dd9a581b00018fd98000104000100013a54e1020007053000014a90430ca7027a27026c27827227613027827427a2684dc4c8991302684c09913027e13427f177245385090dd9a581b"
+
+const testSectorSize = 512
+
+// testMPQFile describes a file of a test archive; pack lays out its block, encrypted with key when non-zero
+type testMPQFile struct {
+    name   string
+    size   int
+    flags  uint32
+    locale uint16
+    pack   func(key uint32) []byte
+}
+
+// writeTestMPQ writes an archive with 512-byte sectors, optionally behind a user data block
+func writeTestMPQ(t *testing.T, path string, userData bool, files []testMPQFile) {
+    t.Helper()
+    start := 0
+    if userData {
+        start = 512
+    }
+    body := make([]byte, start+32)
+    if userData {
+        copy(body, mpqUserDataMagic)
+        binary.LittleEndian.PutUint32(body[8:], uint32(start))
+    }
+
+    hashes := make([]mpqHashEntry, 16)
+    for i := range hashes {
+        hashes[i] = mpqHashEntry{HashA: mpqHashEmpty, HashB: mpqHashEmpty, Locale: 0xFFFF, Platform: 0xFFFF, BlockIndex: mpqHashEmpty}
+    }
+    var blockData []byte
+    for i, f := range files {
+        pos := uint32(len(body) - start)
+        var key uint32
+        if f.flags&mpqFileEncrypted != 0 {
+            key = mpqHash(f.name[strings.LastIndex(f.name, "\\")+1:], 3)
+            if f.flags&mpqFileFixKey != 0 {
+                key = (key + pos) ^ uint32(f.size)
+            }
+        }
+        packed := f.pack(key)
+        body = append(body, packed...)
+
+        blockData = binary.LittleEndian.AppendUint32(blockData, pos)
+        blockData = binary.LittleEndian.AppendUint32(blockData, uint32(len(packed)))
+        blockData = binary.LittleEndian.AppendUint32(blockData, uint32(f.size))
+        blockData = binary.LittleEndian.AppendUint32(blockData, f.flags|mpqFileExists)
+
+        slot := mpqHash(f.name, 0) % uint32(len(hashes))
+        for hashes[slot].BlockIndex != mpqHashEmpty {
+            slot = (slot + 1) % uint32(len(hashes))
+        }
+        hashes[slot] = mpqHashEntry{HashA: mpqHash(f.name, 1), HashB: mpqHash(f.name, 2), Locale: f.locale, BlockIndex: uint32(i)}
+    }
+
+    hashPos := len(body) - start
+    var hashData []byte
+    for _, e := range hashes {
+        hashData = binary.LittleEndian.AppendUint32(hashData, e.HashA)
+        hashData = binary.LittleEndian.AppendUint32(hashData, e.HashB)
+        hashData = binary.LittleEndian.AppendUint16(hashData, e.Locale)
+        hashData = binary.LittleEndian.AppendUint16(hashData, e.Platform)
+        hashData = binary.LittleEndian.AppendUint32(hashData, e.BlockIndex)
+    }
+    mpqEncrypt(hashData, mpqHash("(hash table)", 3))
+    body = append(body, hashData...)
+    blockPos := len(body) - start
+    mpqEncrypt(blockData, mpqHash("(block table)", 3))
+    body = append(body, blockData...)
+
+    h := body[start:]
+    copy(h, mpqMagic)
+    binary.LittleEndian.PutUint32(h[4:], 32)
+    binary.LittleEndian.PutUint32(h[8:], uint32(len(h)))
+    binary.LittleEndian.PutUint32(h[16:], uint32(hashPos))
+    binary.LittleEndian.PutUint32(h[20:], uint32(blockPos))
+    binary.LittleEndian.PutUint32(h[24:], uint32(len(hashes)))
+    binary.LittleEndian.PutUint32(h[28:], uint32(len(files)))
+    if err := os.WriteFile(path, body, 0644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+// plainFile stores data as is
+func plainFile(name string, data []byte) testMPQFile {
+    return testMPQFile{name: name, size: len(data), pack: func(uint32) []byte { return data }}
+}
+
+// zlibFile stores data in zlib sectors behind a sector offset table; sectors that do
+// not shrink are stored raw. Encrypted files encrypt the table with key-1 and sector i with key+i.
+func zlibFile(name string, data []byte, flags uint32) testMPQFile {
+    pack := func(key uint32) []byte {
+        count := (len(data) + testSectorSize - 1) / testSectorSize
+        table := make([]byte, (count+1)*4)
+        var sectors []byte
+        for i := 0; i < count; i++ {
+            binary.LittleEndian.PutUint32(table[i*4:], uint32(len(table)+len(sectors)))
+            sector := data[i*testSectorSize : min((i+1)*testSectorSize, len(data))]
+            var z bytes.Buffer
+            z.WriteByte(mpqCompressZlib)
+            zw := zlib.NewWriter(&z)
+            zw.Write(sector)
+            zw.Close()
+            if z.Len() < len(sector) {
+                sector = z.Bytes()
+            }
+            sector = append([]byte(nil), sector...)
+            if key != 0 {
+                mpqEncrypt(sector, key+uint32(i))
+            }
+            sectors = append(sectors, sector...)
+        }
+        binary.LittleEndian.PutUint32(table[count*4:], uint32(len(table)+len(sectors)))
+        if key != 0 {
+            mpqEncrypt(table, key-1)
+        }
+        return append(table, sectors...)
+    }
+    return testMPQFile{name: name, size: len(data), flags: flags | mpqFileCompress, pack: pack}
+}
+
+// testData returns compressible text followed by incompressible bytes
+func testData(n int) []byte {
+    data := []byte(strings.Repeat("DBCTool MPQ sector data ", n/24))
+    noise := make([]byte, n-len(data))
+    rand.New(rand.NewSource(1)).Read(noise)
+    return append(data, noise...)
+}
+
+func TestMPQHash(t *testing.T) {
+    // well-known keys of the encrypted tables
+    if got := mpqHash("(hash table)", 3); got != 0xC3AF3770 {
+        t.Errorf("hash table key = %08X", got)
+    }
+    if got := mpqHash("(block table)", 3); got != 0xEC83B3A3 {
+        t.Errorf("block table key = %08X", got)
+    }
+    if mpqHash("dbfilesclient/spell.dbc", 1) != mpqHash("DBFilesClient\\Spell.dbc", 1) {
+        t.Error("names should hash case-insensitively with either separator")
+    }
+
+    data := testData(203)
+    enc := append([]byte(nil), data...)
+    mpqEncrypt(enc, 0x12345678)
+    if bytes.Equal(enc[:200], data[:200]) || !bytes.Equal(enc[200:], data[200:]) {
+        t.Error("encryption should cover whole 4-byte blocks only")
+    }
+    mpqDecrypt(enc, 0x12345678)
+    if !bytes.Equal(enc, data) {
+        t.Error("decrypt does not invert encrypt")
+    }
+}
+
+func TestMPQReadFile(t *testing.T) {
+    plain := testData(1300)
+    zipped := testData(2000)
+    bz, _ := hex.DecodeString(testBzip2Hex)
+    bzText := []byte(strings.Repeat("single unit bzip2 file\n", 40))
+
+    path := filepath.Join(t.TempDir(), "test.MPQ")
+    writeTestMPQ(t, path, true, []testMPQFile{
+        plainFile("plain.txt", plain),
+        zlibFile("DBFilesClient\\Spell.dbc", zipped, mpqFileEncrypted|mpqFileFixKey),
+        zlibFile("zlib.bin", zipped, 0),
+        {name: "Data\\bzip2.txt", size: len(bzText), flags: mpqFileCompress | mpqFileSingleUnit | mpqFileEncrypted,
+            pack: func(key uint32) []byte {
+                block := append([]byte{mpqCompressBzip2}, bz...)
+                mpqEncrypt(block, key)
+                return block
+            }},
+        {name: "locale.txt", size: 6, locale: 0x407, pack: func(uint32) []byte { return []byte("german") }},
+        {name: "locale.txt", size: 7, pack: func(uint32) []byte { return []byte("neutral") }},
+    })
+
+    a, err := OpenMPQ(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer a.Close()
+    if a.offset != 512 {
+        t.Errorf("archive offset = %d, want 512 behind the user data block", a.offset)
+    }
+
+    tests := []struct {
+        name string
+        want []byte
+    }{
+        {"plain.txt", plain},
+        {"DBFilesClient\\Spell.dbc", zipped},
+        {"dbfilesclient/spell.dbc", zipped},
+        {"zlib.bin", zipped},
+        {"Data\\bzip2.txt", bzText},
+        {"locale.txt", []byte("neutral")},
+    }
+    for _, tt := range tests {
+        got, err := a.ReadFile(tt.name)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if !bytes.Equal(got, tt.want) {
+            t.Errorf("%s: got %d bytes that differ from the %d stored", tt.name, len(got), len(tt.want))
+        }
+    }
+    if a.Has("missing.txt") {
+        t.Error("Has reports a missing file")
+    }
+    if _, err := a.ReadFile("missing.txt"); err == nil {
+        t.Error("reading a missing file succeeded")
+    }
+}
+
+func TestMPQSetPrecedence(t *testing.T) {
+    dir := t.TempDir()
+    file := func(name, text string) testMPQFile { return plainFile(name, []byte(text)) }
+    deleted := testMPQFile{name: "gone.txt", flags: mpqFileDeleteMarker, pack: func(uint32) []byte { return nil }}
+
+    writeTestMPQ(t, filepath.Join(dir, "common.MPQ"), false, []testMPQFile{
+        file("a.txt", "base a"), file("b.txt", "base b"), file("gone.txt", "base gone"),
+    })
+    writeTestMPQ(t, filepath.Join(dir, "patch.MPQ"), false, []testMPQFile{
+        file("a.txt", "patch a"), file("b.txt", "patch b"), deleted,
+    })
+    writeTestMPQ(t, filepath.Join(dir, "patch-2.MPQ"), false, []testMPQFile{
+        file("a.txt", "patch-2 a"),
+    })
+
+    // patch-2.MPQ sorts after patch.MPQ, so it wins
+    set, err := OpenMPQSet([]string{filepath.Join(dir, "common.MPQ"), filepath.Join(dir, "patch*.MPQ")})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer set.Close()
+    if len(set.Archives) != 3 {
+        t.Fatalf("opened %d archives, want 3", len(set.Archives))
+    }
+
+    for name, want := range map[string]string{"a.txt": "patch-2 a", "b.txt": "patch b"} {
+        data, source, err := set.ReadFile(name)
+        if err != nil {
+            t.Errorf("%s: %v", name, err)
+            continue
+        }
+        if string(data) != want {
+            t.Errorf("%s = %q from %s, want %q", name, data, filepath.Base(source), want)
+        }
+    }
+    if set.Has("gone.txt") {
+        t.Error("a delete marker in a patch should hide the base file")
+    }
+    if _, _, err := set.ReadFile("gone.txt"); err == nil {
+        t.Error("reading a deleted file succeeded")
+    }
+}
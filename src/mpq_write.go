@@ -0,0 +1,474 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "compress/zlib"
+    "encoding/binary"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+)
+
+const (
+    mpqListFile       = "(listfile)"
+    mpqAttributesFile = "(attributes)"
+
+    mpqDefaultSectorShift = 3 // 4096-byte sectors, as used by the client archives
+)
+
+// MPQFile is a file to be stored in an archive
+type MPQFile struct {
+    Name string // archive path, e.g. DBFilesClient\Spell.dbc
+    Data []byte
+}
+
+// mpqEncrypt encrypts data in place, in whole 4-byte blocks
+func mpqEncrypt(data []byte, key uint32) {
+    seed := uint32(0xEEEEEEEE)
+    for i := 0; i+4 <= len(data); i += 4 {
+        seed += mpqCryptTable[0x400+(key&0xFF)]
+        v := binary.LittleEndian.Uint32(data[i:])
+        binary.LittleEndian.PutUint32(data[i:], v^(key+seed))
+        key = ((^key << 21) + 0x11111111) | (key >> 11)
+        seed = v + seed + (seed << 5) + 3
+    }
+}
+
+// WriteMPQ adds files to an archive, creating it if it does not exist. Files
+// already present under the same name are replaced, the (listfile) is updated
+// and the archive is rewritten through a temporary file. Only the data of files
+// that are kept is copied over, so replacing a file does not grow the archive.
+func WriteMPQ(path string, files []MPQFile, compress bool) error {
+    var prefix []byte // user data and header space in front of the file data
+    var header mpqHeader
+    var hashes []mpqHashEntry
+    var archiveOffset int64
+    var listed []string
+    var existing *MPQArchive
+
+    if _, err := os.Stat(path); err == nil {
+        if existing, err = OpenMPQ(path); err != nil {
+            return err
+        }
+        defer func() {
+            if existing != nil {
+                existing.Close()
+            }
+        }()
+        if existing.header.FormatVersion > 1 {
+            return fmt.Errorf("%s: rewriting MPQ format version %d archives is not supported", path, existing.header.FormatVersion+1)
+        }
+
+        header = existing.header
+        hashes = existing.hashes
+        archiveOffset = existing.offset
+
+        prefix = make([]byte, archiveOffset+int64(header.HeaderSize))
+        _, err = existing.file.ReadAt(prefix, 0)
+        if err == nil && existing.Has(mpqListFile) {
+            var list []byte
+            if list, err = existing.ReadFile(mpqListFile); err == nil {
+                listed = parseListFile(list)
+            }
+        }
+        if err != nil {
+            return fmt.Errorf("failed to read %s: %w", path, err)
+        }
+    } else if os.IsNotExist(err) {
+        header = mpqHeader{HeaderSize: 32, SectorSizeShift: mpqDefaultSectorShift}
+        prefix = make([]byte, header.HeaderSize)
+    } else {
+        return err
+    }
+
+    // the (attributes) file holds per-block checksums that would no longer match
+    if i := findHashSlot(hashes, mpqAttributesFile); i >= 0 {
+        hashes[i].BlockIndex = mpqHashDeleted
+    }
+
+    names := map[string]bool{}
+    for _, n := range listed {
+        names[strings.ToUpper(n)] = true
+    }
+    for _, f := range files {
+        if !names[strings.ToUpper(f.Name)] {
+            names[strings.ToUpper(f.Name)] = true
+            listed = append(listed, f.Name)
+        }
+    }
+    sort.Strings(listed)
+    files = append(append([]MPQFile{}, files...), MPQFile{Name: mpqListFile, Data: []byte(strings.Join(listed, "\r\n") + "\r\n")})
+
+    var err error
+    hashes, err = ensureHashCapacity(hashes, listed, len(files))
+    if err != nil {
+        return fmt.Errorf("%s: %w", path, err)
+    }
+
+    var body bytes.Buffer
+    body.Write(prefix)
+    sectorSize := 512 << header.SectorSizeShift
+
+    var blocks []mpqBlockEntry
+    if existing != nil {
+        if blocks, err = copyKeptBlocks(&body, existing, hashes, files, listed); err != nil {
+            return fmt.Errorf("%s: %w", path, err)
+        }
+        // the archive is replaced by rename below, which fails on Windows while it is open
+        existing.Close()
+        existing = nil
+    }
+
+    for _, f := range files {
+        pos := uint64(int64(body.Len()) - archiveOffset)
+        packed, flags := packMPQFile(f.Data, sectorSize, compress)
+        block := mpqBlockEntry{
+            FilePos:        pos,
+            CompressedSize: uint32(len(packed)),
+            FileSize:       uint32(len(f.Data)),
+            Flags:          flags,
+        }
+        body.Write(packed)
+
+        slot := freeHashSlot(hashes, f.Name)
+        hashes[slot] = mpqHashEntry{
+            HashA:      mpqHash(f.Name, 1),
+            HashB:      mpqHash(f.Name, 2),
+            BlockIndex: uint32(len(blocks)),
+        }
+        blocks = append(blocks, block)
+    }
+
+    header.HashTablePos = uint64(int64(body.Len()) - archiveOffset)
+    header.HashTableSize = uint32(len(hashes))
+    hashData := make([]byte, len(hashes)*16)
+    for i, e := range hashes {
+        binary.LittleEndian.PutUint32(hashData[i*16:], e.HashA)
+        binary.LittleEndian.PutUint32(hashData[i*16+4:], e.HashB)
+        binary.LittleEndian.PutUint16(hashData[i*16+8:], e.Locale)
+        binary.LittleEndian.PutUint16(hashData[i*16+10:], e.Platform)
+        binary.LittleEndian.PutUint32(hashData[i*16+12:], e.BlockIndex)
+    }
+    mpqEncrypt(hashData, mpqHash("(hash table)", 3))
+    body.Write(hashData)
+
+    header.BlockTablePos = uint64(int64(body.Len()) - archiveOffset)
+    header.BlockTableSize = uint32(len(blocks))
+    blockData := make([]byte, len(blocks)*16)
+    for i, b := range blocks {
+        binary.LittleEndian.PutUint32(blockData[i*16:], uint32(b.FilePos))
+        binary.LittleEndian.PutUint32(blockData[i*16+4:], b.CompressedSize)
+        binary.LittleEndian.PutUint32(blockData[i*16+8:], b.FileSize)
+        binary.LittleEndian.PutUint32(blockData[i*16+12:], b.Flags)
+    }
+    mpqEncrypt(blockData, mpqHash("(block table)", 3))
+    body.Write(blockData)
+
+    if int64(body.Len())-archiveOffset > 0xFFFFFFFF {
+        return fmt.Errorf("%s: archives larger than 4 GB are not supported", path)
+    }
+    header.ArchiveSize = uint32(int64(body.Len()) - archiveOffset)
+
+    out := body.Bytes()
+    h := out[archiveOffset:]
+    copy(h[0:4], mpqMagic)
+    binary.LittleEndian.PutUint32(h[4:8], header.HeaderSize)
+    binary.LittleEndian.PutUint32(h[8:12], header.ArchiveSize)
+    binary.LittleEndian.PutUint16(h[12:14], header.FormatVersion)
+    binary.LittleEndian.PutUint16(h[14:16], header.SectorSizeShift)
+    binary.LittleEndian.PutUint32(h[16:20], uint32(header.HashTablePos))
+    binary.LittleEndian.PutUint32(h[20:24], uint32(header.BlockTablePos))
+    binary.LittleEndian.PutUint32(h[24:28], header.HashTableSize)
+    binary.LittleEndian.PutUint32(h[28:32], header.BlockTableSize)
+    if header.HeaderSize >= 44 {
+        // no hi-block table and no table position high words below 4 GB
+        for i := 32; i < 44; i++ {
+            h[i] = 0
+        }
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+    if _, err := tmp.Write(out); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+// copyKeptBlocks appends the data of every file in an existing archive that is not
+// about to be replaced to body and returns their blocks at the new positions. The
+// hash entries are pointed at the new blocks; replaced and dangling entries are deleted.
+func copyKeptBlocks(body *bytes.Buffer, existing *MPQArchive, hashes []mpqHashEntry, files []MPQFile, listed []string) ([]mpqBlockEntry, error) {
+    for _, f := range files {
+        if slot := findHashSlot(hashes, f.Name); slot >= 0 {
+            hashes[slot].BlockIndex = mpqHashDeleted
+        }
+    }
+
+    var blocks []mpqBlockEntry
+    moved := map[uint32]uint32{}
+    for i, e := range hashes {
+        if e.BlockIndex == mpqHashEmpty || e.BlockIndex == mpqHashDeleted {
+            continue
+        }
+        if n, ok := moved[e.BlockIndex]; ok {
+            hashes[i].BlockIndex = n
+            continue
+        }
+        if e.BlockIndex >= uint32(len(existing.blocks)) {
+            hashes[i].BlockIndex = mpqHashDeleted
+            continue
+        }
+
+        block := existing.blocks[e.BlockIndex]
+        data := make([]byte, block.CompressedSize)
+        if _, err := existing.file.ReadAt(data, existing.offset+int64(block.FilePos)); err != nil {
+            return nil, fmt.Errorf("failed to read block %d: %w", e.BlockIndex, err)
+        }
+        pos := uint64(int64(body.Len()) - existing.offset)
+        if block.Flags&mpqFileEncrypted != 0 && block.Flags&mpqFileFixKey != 0 && pos != block.FilePos {
+            name := listedName(listed, e)
+            if name == "" {
+                return nil, fmt.Errorf("cannot move encrypted block %d: its name is missing from the (listfile)", e.BlockIndex)
+            }
+            if err := mpqRekey(data, block, name, pos, 512<<existing.header.SectorSizeShift); err != nil {
+                return nil, fmt.Errorf("%s: %w", name, err)
+            }
+        }
+        block.FilePos = pos
+        body.Write(data)
+
+        moved[e.BlockIndex] = uint32(len(blocks))
+        hashes[i].BlockIndex = uint32(len(blocks))
+        blocks = append(blocks, block)
+    }
+    return blocks, nil
+}
+
+// listedName returns the (listfile) name of a hash entry, or "" if it is not listed
+func listedName(listed []string, e mpqHashEntry) string {
+    for _, name := range listed {
+        if mpqHash(name, 1) == e.HashA && mpqHash(name, 2) == e.HashB {
+            return name
+        }
+    }
+    return ""
+}
+
+// mpqRekey re-encrypts the data of a block whose key depends on its position
+// (mpqFileFixKey) for the position pos
+func mpqRekey(data []byte, block mpqBlockEntry, name string, pos uint64, sectorSize int) error {
+    base := mpqHash(name[strings.LastIndexAny(name, "\\/")+1:], 3)
+    oldKey := (base + uint32(block.FilePos)) ^ block.FileSize
+    newKey := (base + uint32(pos)) ^ block.FileSize
+
+    if block.Flags&mpqFileSingleUnit != 0 {
+        mpqDecrypt(data, oldKey)
+        mpqEncrypt(data, newKey)
+        return nil
+    }
+
+    sectorCount := (int(block.FileSize) + sectorSize - 1) / sectorSize
+    var bounds []uint32
+    if block.Flags&mpqFileCompress != 0 {
+        // the sector checksums, if any, follow the last sector and are encrypted like one more sector
+        entries := sectorCount + 1
+        if block.Flags&mpqFileSectorCRC != 0 {
+            entries++
+        }
+        if len(data) < entries*4 {
+            return fmt.Errorf("truncated sector table")
+        }
+        table := data[:entries*4]
+        mpqDecrypt(table, oldKey-1)
+        for i := 0; i < entries; i++ {
+            bounds = append(bounds, binary.LittleEndian.Uint32(table[i*4:]))
+        }
+        mpqEncrypt(table, newKey-1)
+    } else {
+        for i := 0; i <= sectorCount; i++ {
+            bounds = append(bounds, uint32(min(i*sectorSize, int(block.FileSize))))
+        }
+    }
+
+    for i := 0; i+1 < len(bounds); i++ {
+        start, end := bounds[i], bounds[i+1]
+        if end < start || int(end) > len(data) {
+            return fmt.Errorf("corrupt sector %d", i)
+        }
+        mpqDecrypt(data[start:end], oldKey+uint32(i))
+        mpqEncrypt(data[start:end], newKey+uint32(i))
+    }
+    return nil
+}
+
+// packMPQFile lays a file out in sectors, compressing each with zlib when that saves space
+func packMPQFile(data []byte, sectorSize int, compress bool) ([]byte, uint32) {
+    flags := uint32(mpqFileExists)
+    if !compress {
+        return data, flags
+    }
+    flags |= mpqFileCompress
+
+    sectorCount := (len(data) + sectorSize - 1) / sectorSize
+    offsets := make([]byte, (sectorCount+1)*4)
+    var packed bytes.Buffer
+    pos := len(offsets)
+    for i := 0; i < sectorCount; i++ {
+        binary.LittleEndian.PutUint32(offsets[i*4:], uint32(pos))
+        end := (i + 1) * sectorSize
+        if end > len(data) {
+            end = len(data)
+        }
+        sector := data[i*sectorSize : end]
+
+        var z bytes.Buffer
+        z.WriteByte(mpqCompressZlib)
+        zw, _ := zlib.NewWriterLevel(&z, zlib.BestCompression)
+        zw.Write(sector)
+        zw.Close()
+        if z.Len() < len(sector) {
+            sector = z.Bytes()
+        }
+        packed.Write(sector)
+        pos += len(sector)
+    }
+    binary.LittleEndian.PutUint32(offsets[sectorCount*4:], uint32(pos))
+
+    return append(offsets, packed.Bytes()...), flags
+}
+
+// findHashSlot returns the hash table slot holding a file, or -1
+func findHashSlot(hashes []mpqHashEntry, name string) int {
+    size := uint32(len(hashes))
+    if size == 0 {
+        return -1
+    }
+    start := mpqHash(name, 0) % size
+    hashA := mpqHash(name, 1)
+    hashB := mpqHash(name, 2)
+    for i := uint32(0); i < size; i++ {
+        slot := (start + i) % size
+        e := hashes[slot]
+        if e.BlockIndex == mpqHashEmpty {
+            break
+        }
+        if e.BlockIndex != mpqHashDeleted && e.HashA == hashA && e.HashB == hashB && e.Locale == 0 {
+            return int(slot)
+        }
+    }
+    return -1
+}
+
+// freeHashSlot returns the first empty or deleted slot on a file's probe sequence
+func freeHashSlot(hashes []mpqHashEntry, name string) int {
+    size := uint32(len(hashes))
+    start := mpqHash(name, 0) % size
+    for i := uint32(0); i < size; i++ {
+        slot := (start + i) % size
+        if b := hashes[slot].BlockIndex; b == mpqHashEmpty || b == mpqHashDeleted {
+            return int(slot)
+        }
+    }
+    return -1
+}
+
+// ensureHashCapacity grows the hash table when adding files would fill it.
+// Growing rehashes every entry, so all live entries must be named in the listfile.
+func ensureHashCapacity(hashes []mpqHashEntry, names []string, adding int) ([]mpqHashEntry, error) {
+    used := 0
+    for _, e := range hashes {
+        if e.BlockIndex != mpqHashEmpty && e.BlockIndex != mpqHashDeleted {
+            used++
+        }
+    }
+    need := used + adding
+    size := 16
+    for size*3/4 < need {
+        size *= 2
+    }
+    if len(hashes) >= size {
+        return hashes, nil
+    }
+
+    grown := make([]mpqHashEntry, size)
+    for i := range grown {
+        grown[i] = mpqHashEntry{HashA: mpqHashEmpty, HashB: mpqHashEmpty, Locale: 0xFFFF, Platform: 0xFFFF, BlockIndex: mpqHashEmpty}
+    }
+
+    moved := 0
+    all := append(append([]string{}, names...), mpqListFile, mpqAttributesFile)
+    for _, name := range all {
+        hashA, hashB := mpqHash(name, 1), mpqHash(name, 2)
+        for i, e := range hashes {
+            if e.BlockIndex == mpqHashEmpty || e.BlockIndex == mpqHashDeleted || e.HashA != hashA || e.HashB != hashB {
+                continue
+            }
+            slot := freeHashSlot(grown, name)
+            grown[slot] = e
+            hashes[i].BlockIndex = mpqHashDeleted
+            moved++
+        }
+    }
+    if moved != used {
+        return nil, fmt.Errorf("hash table is full and %d existing files are missing from the (listfile)", used-moved)
+    }
+    return grown, nil
+}
+
+// parseListFile splits a (listfile) into names
+func parseListFile(data []byte) []string {
+    var names []string
+    for _, line := range strings.FieldsFunc(string(data), func(r rune) bool {
+        return r == '\r' || r == '\n' || r == ';'
+    }) {
+        if line = strings.TrimSpace(line); line != "" {
+            names = append(names, line)
+        }
+    }
+    return names
+}
+
+// PackExportMPQ writes the exported files of the given metas into an archive under DBFilesClient\
+func PackExportMPQ(cfg *Config, metaPaths []string, archivePath string, compress bool) error {
+    var files []MPQFile
+    for _, metaPath := range metaPaths {
+        meta, err := LoadMeta(metaPath)
+        if err != nil {
+            return err
+        }
+        data, err := os.ReadFile(filepath.Join(cfg.Paths.Export, meta.File))
+        if os.IsNotExist(err) {
+            continue
+        }
+        if err != nil {
+            return err
+        }
+        files = append(files, MPQFile{Name: mpqDBCDir + filepath.Base(meta.File), Data: data})
+    }
+
+    if len(files) == 0 {
+        return fmt.Errorf("no exported files found in %s", cfg.Paths.Export)
+    }
+    if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+        return err
+    }
+    if err := WriteMPQ(archivePath, files, compress); err != nil {
+        return err
+    }
+    return nil
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// readMPQFiles opens an archive and checks that it holds the given files
+func readMPQFiles(t *testing.T, path string, want map[string][]byte) {
+    t.Helper()
+    a, err := OpenMPQ(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer a.Close()
+    for name, data := range want {
+        got, err := a.ReadFile(name)
+        if err != nil {
+            t.Errorf("%s: %v", name, err)
+        } else if !bytes.Equal(got, data) {
+            t.Errorf("%s: read %d bytes that differ from the %d written", name, len(got), len(data))
+        }
+    }
+}
+
+func fileSize(t *testing.T, path string) int64 {
+    t.Helper()
+    info, err := os.Stat(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return info.Size()
+}
+
+func TestWriteMPQRewrite(t *testing.T) {
+    for _, compress := range []bool{false, true} {
+        path := filepath.Join(t.TempDir(), "patch-4.MPQ")
+        spell := testData(60000)
+        files := map[string][]byte{
+            "DBFilesClient\\Spell.dbc":     spell,
+            "DBFilesClient\\SpellIcon.dbc": testData(5000),
+        }
+        if err := WriteMPQ(path, []MPQFile{
+            {Name: "DBFilesClient\\Spell.dbc", Data: spell},
+            {Name: "DBFilesClient\\SpellIcon.dbc", Data: files["DBFilesClient\\SpellIcon.dbc"]},
+        }, compress); err != nil {
+            t.Fatal(err)
+        }
+        readMPQFiles(t, path, files)
+        size := fileSize(t, path)
+
+        // rewriting the same file replaces its data instead of adding to it
+        for i := 0; i < 3; i++ {
+            if err := WriteMPQ(path, []MPQFile{{Name: "DBFilesClient\\Spell.dbc", Data: spell}}, compress); err != nil {
+                t.Fatal(err)
+            }
+            if got := fileSize(t, path); got != size {
+                t.Fatalf("compress=%v: archive grew from %d to %d bytes on rewrite %d", compress, size, got, i+1)
+            }
+        }
+        readMPQFiles(t, path, files)
+
+        files["DBFilesClient\\Spell.dbc"] = testData(30000)
+        if err := WriteMPQ(path, []MPQFile{{Name: "DBFilesClient\\Spell.dbc", Data: files["DBFilesClient\\Spell.dbc"]}}, compress); err != nil {
+            t.Fatal(err)
+        }
+        readMPQFiles(t, path, files)
+        if got := fileSize(t, path); got >= size {
+            t.Errorf("compress=%v: archive is %d bytes after shrinking a file, was %d", compress, got, size)
+        }
+
+        list, err := readListFile(path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if want := []string{"DBFilesClient\\Spell.dbc", "DBFilesClient\\SpellIcon.dbc"}; strings.Join(list, ",") != strings.Join(want, ",") {
+            t.Errorf("listfile = %v, want %v", list, want)
+        }
+    }
+}
+
+// readListFile returns the names in an archive's (listfile)
+func readListFile(path string) ([]string, error) {
+    a, err := OpenMPQ(path)
+    if err != nil {
+        return nil, err
+    }
+    defer a.Close()
+    data, err := a.ReadFile(mpqListFile)
+    if err != nil {
+        return nil, err
+    }
+    return parseListFile(data), nil
+}
+
+func TestWriteMPQMovesEncryptedFiles(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "client.MPQ")
+    first := testData(3000)
+    zipped := testData(2000)
+    single := testData(700)
+    sectored := testData(1500)
+    names := []string{"first.bin", "DBFilesClient\\Spell.dbc", "Data\\single.bin", "Data\\sectored.bin"}
+
+    writeTestMPQ(t, path, false, []testMPQFile{
+        plainFile("first.bin", first),
+        zlibFile("DBFilesClient\\Spell.dbc", zipped, mpqFileEncrypted|mpqFileFixKey),
+        {name: "Data\\single.bin", size: len(single), flags: mpqFileSingleUnit | mpqFileEncrypted | mpqFileFixKey,
+            pack: func(key uint32) []byte {
+                block := append([]byte(nil), single...)
+                mpqEncrypt(block, key)
+                return block
+            }},
+        {name: "Data\\sectored.bin", size: len(sectored), flags: mpqFileEncrypted | mpqFileFixKey,
+            pack: func(key uint32) []byte {
+                block := append([]byte(nil), sectored...)
+                for i := 0; i*testSectorSize < len(block); i++ {
+                    mpqEncrypt(block[i*testSectorSize:min((i+1)*testSectorSize, len(block))], key+uint32(i))
+                }
+                return block
+            }},
+        plainFile(mpqListFile, []byte(strings.Join(names, "\r\n"))),
+    })
+
+    // replacing the first file moves every later one, whose keys depend on their position
+    first = []byte("short")
+    if err := WriteMPQ(path, []MPQFile{{Name: "first.bin", Data: first}}, false); err != nil {
+        t.Fatal(err)
+    }
+    readMPQFiles(t, path, map[string][]byte{
+        "first.bin":               first,
+        "DBFilesClient\\Spell.dbc": zipped,
+        "Data\\single.bin":        single,
+        "Data\\sectored.bin":      sectored,
+    })
+}
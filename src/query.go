@@ -0,0 +1,772 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "io"
+    "regexp"
+    "strconv"
+    "strings"
+    "text/tabwriter"
+)
+
+// Query expressions are C-like: integer, float and string literals, column
+// references, arithmetic and bitwise operators, comparisons, && || ! and ~ / !~
+// for regular expression matches. Columns are referenced by their expanded name
+// (spell_name_enus, attributes_ex_2) or by field, element and locale
+// (spell_name, spell_name.frfr, attributes_ex[2]); a Loc field alone means enUS.
+
+// queryKind is the static type of an expression
+type queryKind int
+
+const (
+    kindInt queryKind = iota
+    kindFloat
+    kindString
+)
+
+func (k queryKind) String() string {
+    switch k {
+    case kindFloat:
+        return "float"
+    case kindString:
+        return "string"
+    default:
+        return "integer"
+    }
+}
+
+// queryValue is the result of evaluating an expression for one record
+type queryValue struct {
+    i int64
+    f float64
+    s string
+}
+
+// queryExpr is a parsed, type-checked expression
+type queryExpr interface {
+    kind() queryKind
+    eval(dbc *DBCFile, row int) (queryValue, error)
+}
+
+// Query is a compiled filter expression
+type Query struct {
+    expr queryExpr
+}
+
+// CompileQuery parses an expression against a schema
+func CompileQuery(schema *Schema, src string) (*Query, error) {
+    p, err := newQueryParser(schema, src)
+    if err != nil {
+        return nil, err
+    }
+    expr, err := p.parseExpr(1)
+    if err != nil {
+        return nil, err
+    }
+    if tok := p.peek(); tok.kind != tokEOF {
+        return nil, fmt.Errorf("unexpected %q at column %d", tok.text, tok.pos+1)
+    }
+    return &Query{expr: expr}, nil
+}
+
+// Match reports whether a record satisfies the query
+func (q *Query) Match(dbc *DBCFile, row int) (bool, error) {
+    v, err := q.expr.eval(dbc, row)
+    if err != nil {
+        return false, err
+    }
+    return truth(q.expr.kind(), v), nil
+}
+
+// Filter returns the rows matching a query, all rows if q is nil, stopping after
+// limit matches when limit is positive
+func Filter(dbc *DBCFile, q *Query, limit int) ([]int, error) {
+    var rows []int
+    for row := 0; row < dbc.Table.Len(); row++ {
+        if limit > 0 && len(rows) == limit {
+            break
+        }
+        if q != nil {
+            ok, err := q.Match(dbc, row)
+            if err != nil {
+                return nil, err
+            }
+            if !ok {
+                continue
+            }
+        }
+        rows = append(rows, row)
+    }
+    return rows, nil
+}
+
+// WriteQueryResult writes the selected columns of rows as an aligned text table,
+// CSV or NDJSON
+func WriteQueryResult(w io.Writer, dbc *DBCFile, rows, cols []int, format string) error {
+    schema := dbc.Table.Schema
+    switch format {
+    case "csv":
+        out := csv.NewWriter(w)
+        record := make([]string, len(cols))
+        for i, c := range cols {
+            record[i] = schema.Columns[c].Name
+        }
+        if err := out.Write(record); err != nil {
+            return err
+        }
+        for _, row := range rows {
+            for i, c := range cols {
+                record[i] = formatCell(dbc, row, c)
+            }
+            if err := out.Write(record); err != nil {
+                return err
+            }
+        }
+        out.Flush()
+        return out.Error()
+    case "ndjson":
+        var buf bytes.Buffer
+        for _, row := range rows {
+            buf.Reset()
+            buf.WriteByte('{')
+            for i, c := range cols {
+                if i > 0 {
+                    buf.WriteByte(',')
+                }
+                buf.WriteString(strconv.Quote(schema.Columns[c].Name))
+                buf.WriteByte(':')
+                buf.WriteString(cellJSON(dbc, row, c))
+            }
+            buf.WriteString("}\n")
+            if _, err := w.Write(buf.Bytes()); err != nil {
+                return err
+            }
+        }
+        return nil
+    default:
+        tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+        record := make([]string, len(cols))
+        for i, c := range cols {
+            record[i] = schema.Columns[c].Name
+        }
+        fmt.Fprintln(tw, strings.Join(record, "\t"))
+        for _, row := range rows {
+            for i, c := range cols {
+                record[i] = formatValue(dbc, row, c)
+            }
+            fmt.Fprintln(tw, strings.Join(record, "\t"))
+        }
+        if err := tw.Flush(); err != nil {
+            return err
+        }
+        _, err := fmt.Fprintf(w, "%d records\n", len(rows))
+        return err
+    }
+}
+
+// SelectColumns resolves a comma-separated list of column references. Array fields
+// without an element select every element.
+func SelectColumns(schema *Schema, list string) ([]int, error) {
+    var cols []int
+    for _, item := range strings.Split(list, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        p, err := newQueryParser(schema, item)
+        if err != nil {
+            return nil, err
+        }
+        refCols, err := p.parseRef()
+        if err != nil {
+            return nil, err
+        }
+        if tok := p.peek(); tok.kind != tokEOF {
+            return nil, fmt.Errorf("unexpected %q in column %s", tok.text, item)
+        }
+        cols = append(cols, refCols...)
+    }
+    return cols, nil
+}
+
+// truth converts a value to a condition: non-zero numbers and non-empty strings are true
+func truth(k queryKind, v queryValue) bool {
+    switch k {
+    case kindFloat:
+        return v.f != 0
+    case kindString:
+        return v.s != ""
+    default:
+        return v.i != 0
+    }
+}
+
+func boolValue(b bool) queryValue {
+    if b {
+        return queryValue{i: 1}
+    }
+    return queryValue{}
+}
+
+// toFloat widens a numeric value to float
+func toFloat(k queryKind, v queryValue) float64 {
+    if k == kindFloat {
+        return v.f
+    }
+    return float64(v.i)
+}
+
+// --- Lexer ---
+
+type queryTokenKind int
+
+const (
+    tokEOF queryTokenKind = iota
+    tokIdent
+    tokInt
+    tokFloat
+    tokString
+    tokOp
+)
+
+type queryToken struct {
+    kind queryTokenKind
+    text string
+    pos  int
+}
+
+// queryOps lists the operators longest first so two-character operators win
+var queryOps = []string{
+    "&&", "||", "==", "!=", "<=", ">=", "<<", ">>", "!~",
+    "~", "<", ">", "!", "&", "|", "^", "+", "-", "*", "/", "%", "(", ")", "[", "]", ".",
+}
+
+// lexQuery splits an expression into tokens
+func lexQuery(src string) ([]queryToken, error) {
+    var toks []queryToken
+    i := 0
+    for i < len(src) {
+        ch := src[i]
+        switch {
+        case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+            i++
+        case ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z':
+            start := i
+            for i < len(src) && (src[i] == '_' || src[i] >= 'a' && src[i] <= 'z' ||
+                src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9') {
+                i++
+            }
+            toks = append(toks, queryToken{tokIdent, src[start:i], start})
+        case ch >= '0' && ch <= '9':
+            start := i
+            kind := tokInt
+            if strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X") {
+                i += 2
+                for i < len(src) && strings.IndexByte("0123456789abcdefABCDEF", src[i]) >= 0 {
+                    i++
+                }
+            } else {
+                for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.' ||
+                    src[i] == 'e' || src[i] == 'E' ||
+                    (src[i] == '-' || src[i] == '+') && (src[i-1] == 'e' || src[i-1] == 'E')) {
+                    if src[i] < '0' || src[i] > '9' {
+                        kind = tokFloat
+                    }
+                    i++
+                }
+            }
+            toks = append(toks, queryToken{kind, src[start:i], start})
+        case ch == '"':
+            quoted, err := strconv.QuotedPrefix(src[i:])
+            if err != nil {
+                return nil, fmt.Errorf("unterminated string at column %d", i+1)
+            }
+            s, _ := strconv.Unquote(quoted)
+            toks = append(toks, queryToken{tokString, s, i})
+            i += len(quoted)
+        default:
+            op := ""
+            for _, o := range queryOps {
+                if strings.HasPrefix(src[i:], o) {
+                    op = o
+                    break
+                }
+            }
+            if op == "" {
+                return nil, fmt.Errorf("unexpected character %q at column %d", ch, i+1)
+            }
+            toks = append(toks, queryToken{tokOp, op, i})
+            i += len(op)
+        }
+    }
+    return append(toks, queryToken{tokEOF, "end of expression", len(src)}), nil
+}
+
+// --- Parser ---
+
+// queryPrecedence gives the binding strength of binary operators, as in Go
+var queryPrecedence = map[string]int{
+    "||": 1,
+    "&&": 2,
+    "==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3, "~": 3, "!~": 3,
+    "+": 4, "-": 4, "|": 4, "^": 4,
+    "*": 5, "/": 5, "%": 5, "&": 5, "<<": 5, ">>": 5,
+}
+
+type queryParser struct {
+    schema *Schema
+    toks   []queryToken
+    pos    int
+}
+
+func newQueryParser(schema *Schema, src string) (*queryParser, error) {
+    toks, err := lexQuery(src)
+    if err != nil {
+        return nil, err
+    }
+    return &queryParser{schema: schema, toks: toks}, nil
+}
+
+func (p *queryParser) peek() queryToken {
+    return p.toks[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+    tok := p.toks[p.pos]
+    if tok.kind != tokEOF {
+        p.pos++
+    }
+    return tok
+}
+
+// accept consumes the operator op if it is next
+func (p *queryParser) accept(op string) bool {
+    if tok := p.peek(); tok.kind == tokOp && tok.text == op {
+        p.pos++
+        return true
+    }
+    return false
+}
+
+// parseExpr parses binary operators binding at least as strongly as minPrec
+func (p *queryParser) parseExpr(minPrec int) (queryExpr, error) {
+    left, err := p.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    for {
+        tok := p.peek()
+        prec, ok := queryPrecedence[tok.text]
+        if tok.kind != tokOp || !ok || prec < minPrec {
+            return left, nil
+        }
+        p.next()
+
+        if tok.text == "~" || tok.text == "!~" {
+            left, err = p.parseMatch(tok, left)
+        } else {
+            var right queryExpr
+            if right, err = p.parseExpr(prec + 1); err == nil {
+                left, err = newBinaryExpr(tok, left, right)
+            }
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+}
+
+// parseMatch parses the pattern of a ~ or !~ operator, which must be a string literal
+func (p *queryParser) parseMatch(op queryToken, left queryExpr) (queryExpr, error) {
+    if left.kind() != kindString {
+        return nil, fmt.Errorf("operator %s at column %d needs a string on the left", op.text, op.pos+1)
+    }
+    tok := p.next()
+    if tok.kind != tokString {
+        return nil, fmt.Errorf("operator %s at column %d needs a string pattern", op.text, op.pos+1)
+    }
+    re, err := regexp.Compile(tok.text)
+    if err != nil {
+        return nil, fmt.Errorf("bad pattern at column %d: %w", tok.pos+1, err)
+    }
+    return &matchExpr{x: left, re: re, negate: op.text == "!~"}, nil
+}
+
+func (p *queryParser) parseUnary() (queryExpr, error) {
+    tok := p.peek()
+    if tok.kind == tokOp && (tok.text == "!" || tok.text == "-" || tok.text == "^") {
+        p.next()
+        x, err := p.parseUnary()
+        if err != nil {
+            return nil, err
+        }
+        // ! tests any value for truth, so "!name" finds empty strings
+        if tok.text != "!" && x.kind() == kindString || tok.text == "^" && x.kind() != kindInt {
+            return nil, fmt.Errorf("operator %s at column %d cannot be applied to a %s", tok.text, tok.pos+1, x.kind())
+        }
+        return &unaryExpr{op: tok.text, x: x}, nil
+    }
+    return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryExpr, error) {
+    tok := p.peek()
+    switch tok.kind {
+    case tokInt:
+        p.next()
+        n, err := strconv.ParseInt(tok.text, 0, 64)
+        if err != nil {
+            u, uerr := strconv.ParseUint(tok.text, 0, 64)
+            if uerr != nil {
+                return nil, fmt.Errorf("bad number %s at column %d", tok.text, tok.pos+1)
+            }
+            n = int64(u)
+        }
+        return &literalExpr{k: kindInt, v: queryValue{i: n}}, nil
+    case tokFloat:
+        p.next()
+        f, err := strconv.ParseFloat(tok.text, 64)
+        if err != nil {
+            return nil, fmt.Errorf("bad number %s at column %d", tok.text, tok.pos+1)
+        }
+        return &literalExpr{k: kindFloat, v: queryValue{f: f}}, nil
+    case tokString:
+        p.next()
+        return &literalExpr{k: kindString, v: queryValue{s: tok.text}}, nil
+    case tokIdent:
+        cols, err := p.parseRef()
+        if err != nil {
+            return nil, err
+        }
+        if len(cols) != 1 {
+            return nil, fmt.Errorf("%s at column %d is an array of %d elements; pick one with [n]", tok.text, tok.pos+1, len(cols))
+        }
+        return newColumnExpr(p.schema, cols[0]), nil
+    }
+
+    if p.accept("(") {
+        x, err := p.parseExpr(1)
+        if err != nil {
+            return nil, err
+        }
+        if !p.accept(")") {
+            next := p.peek()
+            return nil, fmt.Errorf("expected ) at column %d, got %q", next.pos+1, next.text)
+        }
+        return x, nil
+    }
+    return nil, fmt.Errorf("unexpected %q at column %d", tok.text, tok.pos+1)
+}
+
+// parseRef parses a column reference: a column name, or a field name with an
+// optional 1-based [element] and .locale
+func (p *queryParser) parseRef() ([]int, error) {
+    tok := p.next()
+    if tok.kind != tokIdent {
+        return nil, fmt.Errorf("expected a column name at column %d, got %q", tok.pos+1, tok.text)
+    }
+    schema := p.schema
+    if c, ok := schema.Lookup(tok.text); ok && p.peek().text != "[" && p.peek().text != "." {
+        return []int{c}, nil
+    }
+
+    fi := -1
+    for i, f := range schema.Meta.Fields {
+        if f.Name == tok.text {
+            fi = i
+            break
+        }
+    }
+    if fi < 0 {
+        return nil, fmt.Errorf("unknown column %s at column %d", tok.text, tok.pos+1)
+    }
+    field := schema.Meta.Fields[fi]
+
+    elem := -1
+    if p.accept("[") {
+        n := p.next()
+        idx, err := strconv.Atoi(n.text)
+        if n.kind != tokInt || err != nil || idx < 1 || idx > int(field.Count) {
+            return nil, fmt.Errorf("bad element %q of %s at column %d", n.text, field.Name, n.pos+1)
+        }
+        if !p.accept("]") {
+            return nil, fmt.Errorf("expected ] at column %d", p.peek().pos+1)
+        }
+        elem = idx - 1
+    }
+
+    locale := -1
+    if field.Type == "Loc" {
+        locale = 0
+    }
+    if p.accept(".") {
+        lang := p.next()
+        locale = -1
+        for k, l := range locLangs {
+            if l == lang.text {
+                locale = k
+            }
+        }
+        if field.Type != "Loc" || locale < 0 {
+            return nil, fmt.Errorf("bad locale %q of %s at column %d", lang.text, field.Name, lang.pos+1)
+        }
+    }
+
+    var cols []int
+    for c, col := range schema.Columns {
+        if col.Field == fi && (elem < 0 || col.Index == elem) && col.Locale == locale {
+            cols = append(cols, c)
+        }
+    }
+    return cols, nil
+}
+
+// --- Expressions ---
+
+type literalExpr struct {
+    k queryKind
+    v queryValue
+}
+
+func (e *literalExpr) kind() queryKind { return e.k }
+
+func (e *literalExpr) eval(dbc *DBCFile, row int) (queryValue, error) { return e.v, nil }
+
+// columnExpr reads a column; strings are resolved through the string block
+type columnExpr struct {
+    col  int
+    k    queryKind
+    sign bool
+}
+
+func newColumnExpr(schema *Schema, c int) *columnExpr {
+    e := &columnExpr{col: c}
+    switch typ := schema.Columns[c].Type; typ {
+    case "string":
+        e.k = kindString
+    case "float":
+        e.k = kindFloat
+    default:
+        e.k = kindInt
+        e.sign = strings.HasPrefix(typ, "int")
+    }
+    return e
+}
+
+func (e *columnExpr) kind() queryKind { return e.k }
+
+func (e *columnExpr) eval(dbc *DBCFile, row int) (queryValue, error) {
+    switch {
+    case e.k == kindString:
+        return queryValue{s: dbc.Text(row, e.col)}, nil
+    case e.k == kindFloat:
+        return queryValue{f: float64(dbc.Table.Float(row, e.col))}, nil
+    case e.sign:
+        return queryValue{i: dbc.Table.Int(row, e.col)}, nil
+    default:
+        return queryValue{i: int64(dbc.Table.Uint(row, e.col))}, nil
+    }
+}
+
+type unaryExpr struct {
+    op string
+    x  queryExpr
+}
+
+func (e *unaryExpr) kind() queryKind {
+    if e.op == "!" {
+        return kindInt
+    }
+    return e.x.kind()
+}
+
+func (e *unaryExpr) eval(dbc *DBCFile, row int) (queryValue, error) {
+    v, err := e.x.eval(dbc, row)
+    if err != nil {
+        return v, err
+    }
+    switch e.op {
+    case "!":
+        return boolValue(!truth(e.x.kind(), v)), nil
+    case "^":
+        return queryValue{i: ^v.i}, nil
+    }
+    if e.x.kind() == kindFloat {
+        return queryValue{f: -v.f}, nil
+    }
+    return queryValue{i: -v.i}, nil
+}
+
+type binaryExpr struct {
+    op   string
+    l, r queryExpr
+    k    queryKind // result kind
+    num  queryKind // kind operands are evaluated in
+}
+
+// newBinaryExpr type-checks a binary operation
+func newBinaryExpr(op queryToken, l, r queryExpr) (queryExpr, error) {
+    e := &binaryExpr{op: op.text, l: l, r: r}
+    lk, rk := l.kind(), r.kind()
+
+    e.num = kindInt
+    if lk == kindFloat || rk == kindFloat {
+        e.num = kindFloat
+    }
+
+    switch op.text {
+    case "&&", "||":
+        e.k = kindInt
+        return e, nil
+    case "==", "!=", "<", "<=", ">", ">=":
+        e.k = kindInt
+        if (lk == kindString) != (rk == kindString) {
+            return nil, fmt.Errorf("operator %s at column %d compares %s and %s", op.text, op.pos+1, lk, rk)
+        }
+        if lk == kindString {
+            e.num = kindString
+        }
+        return e, nil
+    case "&", "|", "^", "%", "<<", ">>":
+        if lk != kindInt || rk != kindInt {
+            return nil, fmt.Errorf("operator %s at column %d needs integers, got %s and %s", op.text, op.pos+1, lk, rk)
+        }
+    default:
+        if lk == kindString || rk == kindString {
+            return nil, fmt.Errorf("operator %s at column %d needs numbers, got %s and %s", op.text, op.pos+1, lk, rk)
+        }
+    }
+    e.k = e.num
+    return e, nil
+}
+
+func (e *binaryExpr) kind() queryKind { return e.k }
+
+func (e *binaryExpr) eval(dbc *DBCFile, row int) (queryValue, error) {
+    l, err := e.l.eval(dbc, row)
+    if err != nil {
+        return l, err
+    }
+
+    // short-circuit the logical operators
+    if e.op == "&&" || e.op == "||" {
+        lt := truth(e.l.kind(), l)
+        if lt == (e.op == "||") {
+            return boolValue(lt), nil
+        }
+        r, err := e.r.eval(dbc, row)
+        if err != nil {
+            return r, err
+        }
+        return boolValue(truth(e.r.kind(), r)), nil
+    }
+
+    r, err := e.r.eval(dbc, row)
+    if err != nil {
+        return r, err
+    }
+
+    switch e.num {
+    case kindString:
+        return boolValue(compareResult(e.op, strings.Compare(l.s, r.s))), nil
+    case kindFloat:
+        a, b := toFloat(e.l.kind(), l), toFloat(e.r.kind(), r)
+        switch e.op {
+        case "+":
+            return queryValue{f: a + b}, nil
+        case "-":
+            return queryValue{f: a - b}, nil
+        case "*":
+            return queryValue{f: a * b}, nil
+        case "/":
+            return queryValue{f: a / b}, nil
+        }
+        cmp := 0
+        if a < b {
+            cmp = -1
+        } else if a > b {
+            cmp = 1
+        } else if a != b {
+            // NaN compares unequal to everything
+            return boolValue(e.op == "!="), nil
+        }
+        return boolValue(compareResult(e.op, cmp)), nil
+    }
+
+    a, b := l.i, r.i
+    switch e.op {
+    case "+":
+        return queryValue{i: a + b}, nil
+    case "-":
+        return queryValue{i: a - b}, nil
+    case "*":
+        return queryValue{i: a * b}, nil
+    case "/", "%":
+        if b == 0 {
+            return queryValue{}, fmt.Errorf("division by zero in record %d", row)
+        }
+        if e.op == "/" {
+            return queryValue{i: a / b}, nil
+        }
+        return queryValue{i: a % b}, nil
+    case "&":
+        return queryValue{i: a & b}, nil
+    case "|":
+        return queryValue{i: a | b}, nil
+    case "^":
+        return queryValue{i: a ^ b}, nil
+    case "<<":
+        return queryValue{i: a << uint64(b)}, nil
+    case ">>":
+        return queryValue{i: a >> uint64(b)}, nil
+    }
+    cmp := 0
+    if a < b {
+        cmp = -1
+    } else if a > b {
+        cmp = 1
+    }
+    return boolValue(compareResult(e.op, cmp)), nil
+}
+
+// compareResult applies a comparison operator to the sign of a comparison
+func compareResult(op string, cmp int) bool {
+    switch op {
+    case "==":
+        return cmp == 0
+    case "!=":
+        return cmp != 0
+    case "<":
+        return cmp < 0
+    case "<=":
+        return cmp <= 0
+    case ">":
+        return cmp > 0
+    default:
+        return cmp >= 0
+    }
+}
+
+// matchExpr tests a string against a regular expression
+type matchExpr struct {
+    x      queryExpr
+    re     *regexp.Regexp
+    negate bool
+}
+
+func (e *matchExpr) kind() queryKind { return kindInt }
+
+func (e *matchExpr) eval(dbc *DBCFile, row int) (queryValue, error) {
+    v, err := e.x.eval(dbc, row)
+    if err != nil {
+        return v, err
+    }
+    return boolValue(e.re.MatchString(v.s) != e.negate), nil
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// queryTestDBC builds a small table with integer, Loc, array and float fields
+func queryTestDBC(t *testing.T) *DBCFile {
+    t.Helper()
+    meta := &MetaFile{
+        File:        "Test.dbc",
+        PrimaryKeys: []string{"id"},
+        Fields: []FieldMeta{
+            {Name: "id", Type: "uint32"},
+            {Name: "flags", Type: "uint32"},
+            {Name: "name", Type: "Loc"},
+            {Name: "effect", Type: "int32", Count: 3},
+            {Name: "radius", Type: "float"},
+        },
+    }
+    schema, err := NewSchema(meta)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    records := []struct {
+        id, flags    uint32
+        name, nameFr string
+        effect       [3]int32
+        radius       float32
+    }{
+        {1, 4, "Fireball", "Boule de feu", [3]int32{1, 2, 3}, 1.5},
+        {2, 5, "Frostbolt", "", [3]int32{0, 0, 7}, 0},
+        {3, 1, "", "", [3]int32{-1, 0, 0}, 2},
+    }
+
+    dbc := &DBCFile{Table: NewTable(schema, len(records))}
+    strs := newStringTable(nil)
+    set := func(row int, name string, v uint64) {
+        c, ok := schema.Lookup(name)
+        if !ok {
+            t.Fatalf("no column %s", name)
+        }
+        dbc.Table.SetRaw(row, c, v)
+    }
+    for _, r := range records {
+        row := dbc.Table.AppendRow()
+        set(row, "id", uint64(r.id))
+        set(row, "flags", uint64(r.flags))
+        set(row, "name_enus", uint64(strs.offset(r.name)))
+        set(row, "name_frfr", uint64(strs.offset(r.nameFr)))
+        for i, e := range r.effect {
+            set(row, "effect_"+string(rune('1'+i)), uint64(uint32(e)))
+        }
+        set(row, "radius", uint64(math.Float32bits(r.radius)))
+    }
+    dbc.StringBlock = strs.block
+    return dbc
+}
+
+func TestQueryMatch(t *testing.T) {
+    dbc := queryTestDBC(t)
+    tests := []struct {
+        expr string
+        ids  []uint64
+    }{
+        // precedence follows Go: & binds tighter than comparisons, && and ||
+        {`flags & 4 && name ~ "bolt"`, []uint64{2}},
+        {`flags & 4 == 4`, []uint64{1, 2}},
+        {`flags | 2 == 7`, []uint64{2}},
+        {`id == 1 || id == 3 && flags == 4`, []uint64{1}},
+        {`1 + 2 * 3 == 7`, []uint64{1, 2, 3}},
+        {`(1 + 2) * 3 == 9 && id > 2`, []uint64{3}},
+        {`id % 2 == 1`, []uint64{1, 3}},
+        {`-id < -1`, []uint64{2, 3}},
+
+        // strings
+        {`!name`, []uint64{3}},
+        {`!(name ~ "ball")`, []uint64{2, 3}},
+        {`name !~ "^F"`, []uint64{3}},
+        {`name > "G"`, nil},
+        {`name == "Frostbolt"`, []uint64{2}},
+
+        // array elements and locales
+        {`effect[3] == 7`, []uint64{2}},
+        {`effect_3 == 7`, []uint64{2}},
+        {`effect[1] < 0`, []uint64{3}},
+        {`name.frfr == "Boule de feu"`, []uint64{1}},
+        {`name_frfr ~ "Boule"`, []uint64{1}},
+        {`name.enus ~ "^Fire"`, []uint64{1}},
+
+        // floats
+        {`radius > 1`, []uint64{1, 3}},
+        {`radius == 1.5`, []uint64{1}},
+        {`radius * 2 == id + 1`, []uint64{3}},
+        {`radius / 0 > 0`, []uint64{1, 3}},
+    }
+    for _, tt := range tests {
+        q, err := CompileQuery(dbc.Table.Schema, tt.expr)
+        if err != nil {
+            t.Errorf("%s: %v", tt.expr, err)
+            continue
+        }
+        rows, err := Filter(dbc, q, 0)
+        if err != nil {
+            t.Errorf("%s: %v", tt.expr, err)
+            continue
+        }
+        var ids []uint64
+        for _, row := range rows {
+            ids = append(ids, dbc.Table.Uint(row, 0))
+        }
+        if !reflect.DeepEqual(ids, tt.ids) {
+            t.Errorf("%s: got ids %v, want %v", tt.expr, ids, tt.ids)
+        }
+    }
+}
+
+func TestQueryCompileErrors(t *testing.T) {
+    dbc := queryTestDBC(t)
+    tests := []struct {
+        expr string
+        err  string
+    }{
+        {`name + 1`, "needs numbers"},
+        {`-name`, "cannot be applied to a string"},
+        {`^radius`, "cannot be applied to a float"},
+        {`flags & radius`, "needs integers"},
+        {`name == 1`, "compares string and integer"},
+        {`flags ~ "x"`, "needs a string on the left"},
+        {`name ~ flags`, "needs a string pattern"},
+        {`name ~ "("`, "bad pattern"},
+        {`effect == 1`, "array of 3 elements"},
+        {`effect[4] == 1`, "bad element"},
+        {`name.xxxx == ""`, "bad locale"},
+        {`flags.frfr == 1`, "bad locale"},
+        {`nosuch == 1`, "unknown column"},
+        {`(id == 1`, "expected )"},
+        {`id == 1 2`, "unexpected"},
+        {`name == "open`, "unterminated string"},
+    }
+    for _, tt := range tests {
+        _, err := CompileQuery(dbc.Table.Schema, tt.expr)
+        if err == nil || !strings.Contains(err.Error(), tt.err) {
+            t.Errorf("%s: got error %v, want %q", tt.expr, err, tt.err)
+        }
+    }
+}
+
+func TestQueryDivisionByZero(t *testing.T) {
+    dbc := queryTestDBC(t)
+    for _, expr := range []string{`id / 0 == 1`, `id % (flags - flags) == 0`} {
+        q, err := CompileQuery(dbc.Table.Schema, expr)
+        if err != nil {
+            t.Fatalf("%s: %v", expr, err)
+        }
+        if _, err := Filter(dbc, q, 0); err == nil || !strings.Contains(err.Error(), "division by zero") {
+            t.Errorf("%s: got error %v, want division by zero", expr, err)
+        }
+    }
+}
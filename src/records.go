@@ -0,0 +1,341 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "sort"
+    "strings"
+)
+
+// recordHeaderFile holds the file name and header of a DBC split into records
+const recordHeaderFile = "_header.json"
+
+// recordFileHeader is the content of _header.json
+type recordFileHeader struct {
+    File    string     `json:"file"`
+    Header  jsonHeader `json:"header"`
+    Records []string   `json:"records,omitempty"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// WriteRecordFiles writes every record of a DBC to its own indented JSON file in dir,
+// named by primary key, plus _header.json. Record files listed by an earlier
+// export's _header.json are removed so deleted records do not come back on build;
+// other files in dir are left alone.
+func WriteRecordFiles(dir string, dbc *DBCFile) (int, error) {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return 0, err
+    }
+    if err := removeRecordFiles(dir); err != nil {
+        return 0, err
+    }
+
+    schema := dbc.Table.Schema
+    h := dbc.Header
+    names := recordFileNames(dbc)
+    head := recordFileHeader{File: schema.Meta.File, Records: names, Header: jsonHeader{
+        Magic:       string(h.Magic[:]),
+        RecordCount: h.RecordCount,
+        FieldCount:  h.FieldCount,
+        RecordSize:  h.RecordSize,
+    }}
+    if h.Format() == FormatWDB2 {
+        jh := &head.Header
+        jh.TableHash, jh.Build, jh.Timestamp, jh.Locale = h.TableHash, h.Build, h.Timestamp, h.Locale
+        jh.MinID, jh.MaxID = h.MinID, h.MaxID
+        jh.CopyTable = dbc.CopyTable
+    }
+    data, err := json.MarshalIndent(head, "", "  ")
+    if err != nil {
+        return 0, err
+    }
+    if err := os.WriteFile(filepath.Join(dir, recordHeaderFile), append(data, '\n'), 0644); err != nil {
+        return 0, err
+    }
+
+    fieldCols := fieldColumns(schema)
+    for row, name := range names {
+        var buf bytes.Buffer
+        if err := json.Indent(&buf, recordJSON(dbc, row, fieldCols), "", "  "); err != nil {
+            return 0, err
+        }
+        buf.WriteByte('\n')
+        if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+            return 0, err
+        }
+    }
+    return len(names), nil
+}
+
+// removeRecordFiles deletes the record files an earlier export listed in dir's _header.json
+func removeRecordFiles(dir string) error {
+    data, err := os.ReadFile(filepath.Join(dir, recordHeaderFile))
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    var head recordFileHeader
+    if err := json.Unmarshal(data, &head); err != nil {
+        return fmt.Errorf("invalid %s: %w", recordHeaderFile, err)
+    }
+    for _, name := range head.Records {
+        // only plain file names are ours to delete
+        if name != filepath.Base(name) || filepath.Ext(name) != ".json" || name == recordHeaderFile {
+            continue
+        }
+        if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+    }
+    return nil
+}
+
+// recordFileNames names each record by its primary key values joined with '_'.
+// Tables without a usable key are named by zero-padded position.
+func recordFileNames(dbc *DBCFile) []string {
+    keyCols := keyColumns(dbc.Table.Schema)
+    names := make([]string, dbc.Table.Len())
+    width := len(fmt.Sprint(len(names)))
+    seen := map[string]int{}
+    for row := range names {
+        var name string
+        if len(keyCols) == 0 {
+            name = fmt.Sprintf("%0*d", width, row)
+        } else {
+            parts := make([]string, len(keyCols))
+            for i, c := range keyCols {
+                parts[i] = formatCell(dbc, row, c)
+            }
+            name = unsafeFileChars.ReplaceAllString(strings.Join(parts, "_"), "-")
+        }
+        // duplicate keys get a suffix rather than overwriting each other
+        if n := seen[name]; n > 0 {
+            seen[name] = n + 1
+            name = fmt.Sprintf("%s~%d", name, n+1)
+        } else {
+            seen[name] = 1
+        }
+        names[row] = name + ".json"
+    }
+    return names
+}
+
+// ReadRecordFiles assembles a directory written by WriteRecordFiles into a DBC.
+// Records are ordered by the meta's sortOrder, then by file name, and the string
+// block is built in record order.
+func ReadRecordFiles(dir string, schema *Schema) (*DBCFile, error) {
+    var head recordFileHeader
+    data, err := os.ReadFile(filepath.Join(dir, recordHeaderFile))
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(data, &head); err != nil {
+        return nil, fmt.Errorf("invalid %s: %w", recordHeaderFile, err)
+    }
+    if head.Header.Magic != "" && head.Header.Magic != schema.Meta.FileFormat() {
+        return nil, fmt.Errorf("%s is %s but meta declares %s", recordHeaderFile, head.Header.Magic, schema.Meta.FileFormat())
+    }
+
+    paths, err := recordFilePaths(dir, schema, head.Records)
+    if err != nil {
+        return nil, err
+    }
+
+    // parse in file name order, then reorder by sortOrder
+    parsed := &DBCFile{Table: NewTable(schema, len(paths))}
+    strs := newStringTable(nil)
+    fieldCols := fieldColumns(schema)
+    for _, path := range paths {
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        dec := json.NewDecoder(bytes.NewReader(data))
+        dec.UseNumber()
+        var rec map[string]json.RawMessage
+        if err := dec.Decode(&rec); err != nil {
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+        if err := setRecordJSON(parsed, parsed.Table.AppendRow(), rec, fieldCols, strs); err != nil {
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+    }
+    parsed.StringBlock = strs.block
+
+    order := make([]int, parsed.Table.Len())
+    for i := range order {
+        order[i] = i
+    }
+    sortCols, desc := sortColumns(schema)
+    sort.SliceStable(order, func(i, j int) bool {
+        for k, c := range sortCols {
+            if cmp := compareCells(parsed, order[i], order[j], c); cmp != 0 {
+                return (cmp < 0) != desc[k]
+            }
+        }
+        return false
+    })
+
+    dbc := &DBCFile{Table: NewTable(schema, len(order)), StringBlock: []byte{0}}
+    copy(dbc.Header.Magic[:], schema.Meta.FileFormat())
+    offsets := map[string]uint32{"": 0}
+    for _, src := range order {
+        row := dbc.Table.AppendRow()
+        for c, col := range schema.Columns {
+            if col.Type == "string" {
+                dbc.Table.SetRaw(row, c, uint64(getStringOffset(parsed.Text(src, c), &dbc.StringBlock, offsets)))
+            } else {
+                dbc.Table.SetRaw(row, c, parsed.Table.Raw(src, c))
+            }
+        }
+    }
+
+    var prev *DBCFile
+    if dbc.Header.Format() == FormatWDB2 {
+        jh := head.Header
+        prev = &DBCFile{CopyTable: jh.CopyTable}
+        // the index arrays are rebuilt; a zero max_id means the original had none
+        prev.Header = DBCHeader{Magic: dbc.Header.Magic, TableHash: jh.TableHash, Build: jh.Build,
+            Timestamp: jh.Timestamp, Locale: jh.Locale, MinID: jh.MinID, MaxID: jh.MaxID}
+    }
+    if err := FinishHeader(dbc, prev); err != nil {
+        return nil, err
+    }
+    return dbc, nil
+}
+
+// recordFilePaths lists the record files in dir in file name order: the files an
+// export listed in _header.json that still exist, plus files added since whose
+// names have the form of a record key. Other JSON files are not records.
+func recordFilePaths(dir string, schema *Schema, listed []string) ([]string, error) {
+    matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+    if err != nil {
+        return nil, err
+    }
+    pattern := recordFilePattern(schema)
+    var paths []string
+    for _, path := range matches {
+        name := filepath.Base(path)
+        if name != recordHeaderFile && (slices.Contains(listed, name) || pattern.MatchString(name)) {
+            paths = append(paths, path)
+        }
+    }
+    sort.Slice(paths, func(i, j int) bool {
+        return lessFileName(filepath.Base(paths[i]), filepath.Base(paths[j]))
+    })
+    return paths, nil
+}
+
+// recordFilePattern matches the file names recordFileNames gives records of schema
+func recordFilePattern(schema *Schema) *regexp.Regexp {
+    keyCols := keyColumns(schema)
+    parts := make([]string, len(keyCols))
+    for i, c := range keyCols {
+        switch typ := schema.Columns[c].Type; {
+        case typ == "string":
+            parts[i] = `[A-Za-z0-9._-]*`
+        case typ == "float":
+            parts[i] = `[A-Za-z0-9.-]+`
+        case strings.HasPrefix(typ, "int"):
+            parts[i] = `-?[0-9]+`
+        default:
+            parts[i] = `[0-9]+`
+        }
+    }
+    key := strings.Join(parts, "_")
+    if len(keyCols) == 0 {
+        key = `[0-9]+`
+    }
+    return regexp.MustCompile(`^` + key + `(~[0-9]+)?\.json$`)
+}
+
+// lessFileName orders file names with runs of digits compared by value, so 2.json comes before 10.json
+func lessFileName(a, b string) bool {
+    for a != "" && b != "" {
+        da, db := digitPrefix(a), digitPrefix(b)
+        if da > 0 && db > 0 {
+            na, nb := strings.TrimLeft(a[:da], "0"), strings.TrimLeft(b[:db], "0")
+            if len(na) != len(nb) {
+                return len(na) < len(nb)
+            }
+            if na != nb {
+                return na < nb
+            }
+            a, b = a[da:], b[db:]
+            continue
+        }
+        if a[0] != b[0] {
+            return a[0] < b[0]
+        }
+        a, b = a[1:], b[1:]
+    }
+    return len(a) < len(b)
+}
+
+// digitPrefix returns the length of the run of ASCII digits at the start of s
+func digitPrefix(s string) int {
+    n := 0
+    for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+        n++
+    }
+    return n
+}
+
+// sortColumns resolves the meta's sortOrder to columns; unknown names such as auto_id are skipped
+func sortColumns(schema *Schema) ([]int, []bool) {
+    var cols []int
+    var desc []bool
+    for _, sf := range schema.Meta.SortOrder {
+        if c, ok := schema.Lookup(sf.Name); ok {
+            cols = append(cols, c)
+            desc = append(desc, strings.EqualFold(sf.Direction, "DESC"))
+        }
+    }
+    return cols, desc
+}
+
+// compareCells orders two rows by one column according to its type
+func compareCells(dbc *DBCFile, a, b, c int) int {
+    t := dbc.Table
+    switch t.Schema.Columns[c].Type {
+    case "string":
+        return strings.Compare(dbc.Text(a, c), dbc.Text(b, c))
+    case "float":
+        x, y := t.Float(a, c), t.Float(b, c)
+        if x < y {
+            return -1
+        } else if x > y {
+            return 1
+        }
+        return 0
+    case "int8", "int16", "int32", "int64":
+        x, y := t.Int(a, c), t.Int(b, c)
+        if x < y {
+            return -1
+        } else if x > y {
+            return 1
+        }
+        return 0
+    default:
+        x, y := t.Raw(a, c), t.Raw(b, c)
+        if x < y {
+            return -1
+        } else if x > y {
+            return 1
+        }
+        return 0
+    }
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func TestRecordFilesBuild(t *testing.T) {
+    dbc := queryTestDBC(t)
+    copy(dbc.Header.Magic[:], FormatWDBC)
+    dir := t.TempDir()
+    if _, err := WriteRecordFiles(dir, dbc); err != nil {
+        t.Fatal(err)
+    }
+
+    // a deleted record, an added one, and files that are not records
+    write := func(name, text string) {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := os.Remove(filepath.Join(dir, "2.json")); err != nil {
+        t.Fatal(err)
+    }
+    write("10.json", `{"id":10,"name":{"enus":"Added"}}`)
+    write("notes.json", `{"todo":"not a record"}`)
+    write("package.json", `{}`)
+
+    built, err := ReadRecordFiles(dir, dbc.Table.Schema)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var ids []uint64
+    for row := 0; row < built.Table.Len(); row++ {
+        ids = append(ids, built.Table.Uint(row, 0))
+    }
+    if want := []uint64{1, 3, 10}; !reflect.DeepEqual(ids, want) {
+        t.Errorf("ids = %v, want %v", ids, want)
+    }
+
+    // re-exporting replaces the records but keeps the other files
+    if _, err := WriteRecordFiles(dir, built); err != nil {
+        t.Fatal(err)
+    }
+    for _, name := range []string{"notes.json", "package.json", "10.json"} {
+        if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+            t.Errorf("%s: %v", name, err)
+        }
+    }
+}
+
+func TestRecordFilePattern(t *testing.T) {
+    schema := queryTestDBC(t).Table.Schema
+    pattern := recordFilePattern(schema)
+    for name, want := range map[string]bool{
+        "133.json":   true,
+        "133~2.json": true,
+        "notes.json": false,
+        "133.txt":    false,
+        "-1.json":    false, // id is unsigned
+    } {
+        if got := pattern.MatchString(name); got != want {
+            t.Errorf("%s: match = %v, want %v", name, got, want)
+        }
+    }
+}
@@ -0,0 +1,425 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// FieldRef declares that a field holds ids of another table. Array fields apply
+// it to every element.
+type FieldRef struct {
+    Table string  `json:"table"`           // meta name of the referenced table, e.g. spellicon
+    Field string  `json:"field,omitempty"` // referenced field, default its first primary key
+    None  []int64 `json:"none,omitempty"`  // values that mean no reference, e.g. [0]
+}
+
+// DanglingRef is a field value with no matching record in the referenced table
+type DanglingRef struct {
+    Table  string // DBC file or SQL table holding the reference
+    Key    string
+    Column string
+    Value  int64
+    Target string // referenced meta and field, e.g. spellicon.id
+}
+
+// isNone reports whether a value is one of the reference's "no reference" sentinels
+func (r *FieldRef) isNone(v int64) bool {
+    return containsInt(r.None, v)
+}
+
+func containsInt(list []int64, v int64) bool {
+    for _, n := range list {
+        if n == v {
+            return true
+        }
+    }
+    return false
+}
+
+// findMeta loads the meta of a referenced table by name, ignoring case
+func findMeta(metaDir, name string) (MetaFile, error) {
+    path := filepath.Join(metaDir, name+".meta.json")
+    if _, err := os.Stat(path); err != nil {
+        all, _ := filepath.Glob(filepath.Join(metaDir, "*.meta.json"))
+        for _, p := range all {
+            if strings.EqualFold(strings.TrimSuffix(filepath.Base(p), ".meta.json"), name) {
+                path = p
+                break
+            }
+        }
+    }
+    return LoadMeta(path)
+}
+
+// refTarget resolves the meta and column a reference points to
+func refTarget(metaDir string, ref *FieldRef) (MetaFile, string, error) {
+    meta, err := findMeta(metaDir, ref.Table)
+    if err != nil {
+        return MetaFile{}, "", err
+    }
+    field := ref.Field
+    if field == "" {
+        if len(meta.PrimaryKeys) == 0 {
+            return MetaFile{}, "", fmt.Errorf("%s has no primary key to reference", ref.Table)
+        }
+        field = meta.PrimaryKeys[0]
+    }
+    schema, err := NewSchema(&meta)
+    if err != nil {
+        return MetaFile{}, "", err
+    }
+    c, ok := schema.Lookup(field)
+    if !ok {
+        return MetaFile{}, "", fmt.Errorf("%s has no column %q%s", ref.Table, field, columnHint(&meta, field))
+    }
+    switch schema.Columns[c].Type {
+    case "float", "string":
+        return MetaFile{}, "", fmt.Errorf("%s.%s is a %s, not an id", ref.Table, field, schema.Columns[c].Type)
+    }
+    return meta, field, nil
+}
+
+// CheckRefsDBC checks the references declared by the given metas against the DBC
+// files. Referenced tables are loaded once and shared by all references to them.
+func CheckRefsDBC(cfg *Config, metaPaths []string) ([]DanglingRef, error) {
+    idSets := map[string]map[int64]bool{}
+    targetIDs := func(ref *FieldRef) (map[int64]bool, string, error) {
+        meta, field, err := refTarget(cfg.Paths.Meta, ref)
+        if err != nil {
+            return nil, "", err
+        }
+        target := ref.Table + "." + field
+        if ids, ok := idSets[target]; ok {
+            return ids, target, nil
+        }
+        if !dbcExists(cfg, meta.File) {
+            idSets[target] = nil
+            return nil, target, nil
+        }
+        dbc, err := LoadDBCFile(cfg, meta)
+        if err != nil {
+            return nil, "", fmt.Errorf("failed to load %s: %w", meta.File, err)
+        }
+        c, _ := dbc.Table.Schema.Lookup(field)
+        ids := make(map[int64]bool, dbc.Table.Len())
+        for row := 0; row < dbc.Table.Len(); row++ {
+            ids[dbc.Table.Int(row, c)] = true
+        }
+        idSets[target] = ids
+        return ids, target, nil
+    }
+
+    var dangling []DanglingRef
+    for _, metaPath := range metaPaths {
+        meta, err := LoadMeta(metaPath)
+        if err != nil {
+            return nil, err
+        }
+        if !hasRefs(&meta) {
+            continue
+        }
+        if !dbcExists(cfg, meta.File) {
+            log.Printf("Skipping %s: DBC file does not exist", meta.File)
+            continue
+        }
+        dbc, err := LoadDBCFile(cfg, meta)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load %s: %w", meta.File, err)
+        }
+
+        schema := dbc.Table.Schema
+        keyCols := keyColumns(schema)
+        for fi, f := range meta.Fields {
+            if f.Ref == nil {
+                continue
+            }
+            ids, target, err := targetIDs(f.Ref)
+            if err != nil {
+                return nil, fmt.Errorf("%s field %s: %w", meta.File, f.Name, err)
+            }
+            if ids == nil {
+                log.Printf("Skipping %s.%s: referenced DBC for %s does not exist", meta.File, f.Name, target)
+                continue
+            }
+            for c, col := range schema.Columns {
+                if col.Field != fi {
+                    continue
+                }
+                for row := 0; row < dbc.Table.Len(); row++ {
+                    v := dbc.Table.Int(row, c)
+                    if ids[v] || f.Ref.isNone(v) {
+                        continue
+                    }
+                    dangling = append(dangling, DanglingRef{
+                        Table:  meta.File,
+                        Key:    recordLabel(&dbc, row, keyCols),
+                        Column: col.Name,
+                        Value:  v,
+                        Target: target,
+                    })
+                }
+            }
+        }
+    }
+    return dangling, nil
+}
+
+// CheckRefsDB checks the references declared by the given metas against the
+// imported tables, with one anti-join per referencing column
+func CheckRefsDB(db *DB, cfg *Config, metaPaths []string) ([]DanglingRef, error) {
+    d := db.Dialect
+    var dangling []DanglingRef
+    for _, metaPath := range metaPaths {
+        meta, err := LoadMeta(metaPath)
+        if err != nil {
+            return nil, err
+        }
+        if !hasRefs(&meta) {
+            continue
+        }
+        tableName := resolveTableName(&meta, cfg)
+        if exists, err := d.TableExists(db.DB, tableName); err != nil || !exists {
+            log.Printf("Skipping %s: table does not exist", tableName)
+            continue
+        }
+
+        schema, err := NewSchema(&meta)
+        if err != nil {
+            return nil, fmt.Errorf("invalid meta %s: %w", metaPath, err)
+        }
+        var keyNames []string
+        for _, c := range keyColumns(schema) {
+            keyNames = append(keyNames, schema.Columns[c].Name)
+        }
+
+        for fi, f := range meta.Fields {
+            if f.Ref == nil {
+                continue
+            }
+            targetMeta, field, err := refTarget(cfg.Paths.Meta, f.Ref)
+            if err != nil {
+                return nil, fmt.Errorf("%s field %s: %w", meta.File, f.Name, err)
+            }
+            targetTable := resolveTableName(&targetMeta, cfg)
+            if exists, err := d.TableExists(db.DB, targetTable); err != nil || !exists {
+                log.Printf("Skipping %s.%s: referenced table %s does not exist", tableName, f.Name, targetTable)
+                continue
+            }
+
+            for _, col := range schema.Columns {
+                if col.Field != fi {
+                    continue
+                }
+                refs, err := danglingRows(db, tableName, targetTable, col.Name, field, keyNames, f.Ref.None)
+                if err != nil {
+                    return nil, fmt.Errorf("failed to check %s.%s: %w", tableName, col.Name, err)
+                }
+                for _, r := range refs {
+                    r.Column = col.Name
+                    r.Target = f.Ref.Table + "." + field
+                    dangling = append(dangling, r)
+                }
+            }
+        }
+    }
+    return dangling, nil
+}
+
+// danglingRows selects the primary key and value of the rows of table whose
+// column has no match in target
+func danglingRows(db *DB, table, target, column, field string, keyNames []string, none []int64) ([]DanglingRef, error) {
+    d := db.Dialect
+    col := "s." + d.Quote(column)
+    var selected []string
+    for _, k := range keyNames {
+        selected = append(selected, "s."+d.Quote(k))
+    }
+    query := fmt.Sprintf("SELECT %s FROM %s s LEFT JOIN %s t ON t.%s = %s WHERE t.%s IS NULL",
+        strings.Join(append(selected, col), ", "),
+        d.Quote(table), d.Quote(target), d.Quote(field), col, d.Quote(field))
+    args := make([]interface{}, len(none))
+    if len(none) > 0 {
+        query += fmt.Sprintf(" AND %s NOT IN (%s)", col, strings.TrimSuffix(strings.Repeat("?, ", len(none)), ", "))
+        for i, n := range none {
+            args[i] = n
+        }
+    }
+    if len(selected) > 0 {
+        query += " ORDER BY " + strings.Join(selected, ", ")
+    }
+
+    rows, err := db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var refs []DanglingRef
+    keys := make([]string, len(keyNames))
+    dest := make([]interface{}, len(keyNames)+1)
+    for i := range keys {
+        dest[i] = &keys[i]
+    }
+    for rows.Next() {
+        r := DanglingRef{Table: table, Key: "-"}
+        dest[len(keys)] = &r.Value
+        if err := rows.Scan(dest...); err != nil {
+            return nil, err
+        }
+        if len(keys) > 0 {
+            parts := make([]string, len(keys))
+            for i, k := range keys {
+                parts[i] = keyNames[i] + "=" + k
+            }
+            r.Key = strings.Join(parts, ",")
+        }
+        refs = append(refs, r)
+    }
+    return refs, rows.Err()
+}
+
+// hasRefs reports whether any field of a meta declares a reference
+func hasRefs(meta *MetaFile) bool {
+    for _, f := range meta.Fields {
+        if f.Ref != nil {
+            return true
+        }
+    }
+    return false
+}
+
+// foreignKey is a meta reference of one column resolved to SQL tables
+type foreignKey struct {
+    Column    int
+    RefTable  string
+    RefColumn string
+    None      []int64 // stored as NULL so the constraint holds
+}
+
+// foreignKeys resolves the references of a schema to constraints. A reference
+// only becomes one if its target is the single-column primary key of the
+// referenced table and has the same type, and it has at most one none value,
+// which is stored as NULL; the others are just indexed.
+func foreignKeys(cfg *Config, tableName string, schema *Schema) []foreignKey {
+    var fks []foreignKey
+    for fi, f := range schema.Meta.Fields {
+        if f.Ref == nil {
+            continue
+        }
+        if len(f.Ref.None) > 1 {
+            // NULL could only be read back as one of them
+            log.Printf("No foreign key for %s.%s: none lists %d values", tableName, f.Name, len(f.Ref.None))
+            continue
+        }
+        targetMeta, field, err := refTarget(cfg.Paths.Meta, f.Ref)
+        if err != nil {
+            log.Printf("No foreign key for %s.%s: %v", tableName, f.Name, err)
+            continue
+        }
+        if len(targetMeta.PrimaryKeys) != 1 || targetMeta.PrimaryKeys[0] != field {
+            log.Printf("No foreign key for %s.%s: %s.%s is not the primary key", tableName, f.Name, f.Ref.Table, field)
+            continue
+        }
+        if target := fieldType(&targetMeta, field); target != f.Type {
+            log.Printf("No foreign key for %s.%s: %s does not match %s.%s %s", tableName, f.Name, f.Type, f.Ref.Table, field, target)
+            continue
+        }
+
+        refTable := resolveTableName(&targetMeta, cfg)
+        for c, col := range schema.Columns {
+            if col.Field == fi {
+                fks = append(fks, foreignKey{Column: c, RefTable: refTable, RefColumn: field, None: f.Ref.None})
+            }
+        }
+    }
+    return fks
+}
+
+// fieldType returns the type of a meta field
+func fieldType(meta *MetaFile, name string) string {
+    for _, f := range meta.Fields {
+        if f.Name == name {
+            return f.Type
+        }
+    }
+    return ""
+}
+
+// refNulls maps the columns of foreign keys to the values stored as NULL
+func refNulls(fks []foreignKey) map[int][]int64 {
+    nulls := map[int][]int64{}
+    for _, fk := range fks {
+        if len(fk.None) > 0 {
+            nulls[fk.Column] = fk.None
+        }
+    }
+    return nulls
+}
+
+// AddForeignKeys adds the foreign keys of every meta that the dialect declares
+// after loading, for tables where both sides exist and the key is still missing
+func AddForeignKeys(db *DB, cfg *Config) error {
+    d := db.Dialect
+    if !d.DeferredForeignKeys() {
+        return nil // declared by CREATE TABLE
+    }
+
+    metas, err := filepath.Glob(filepath.Join(cfg.Paths.Meta, "*.meta.json"))
+    if err != nil {
+        return fmt.Errorf("failed to scan meta directory: %w", err)
+    }
+
+    for _, metaPath := range metas {
+        meta, err := LoadMeta(metaPath)
+        if err != nil {
+            return fmt.Errorf("failed to load meta %s: %w", metaPath, err)
+        }
+        if !hasRefs(&meta) {
+            continue
+        }
+        tableName := resolveTableName(&meta, cfg)
+        if exists, err := d.TableExists(db.DB, tableName); err != nil || !exists {
+            continue
+        }
+        schema, err := NewSchema(&meta)
+        if err != nil {
+            return fmt.Errorf("invalid meta %s: %w", metaPath, err)
+        }
+
+        for _, fk := range foreignKeys(cfg, tableName, schema) {
+            col := schema.Columns[fk.Column].Name
+            _, stmt := d.ForeignKey(tableName, col, fk.RefTable, fk.RefColumn)
+            if exists, err := d.TableExists(db.DB, fk.RefTable); err != nil || !exists {
+                continue
+            }
+            exists, err := constraintExists(db, tableName, foreignKeyName(tableName, col))
+            if err != nil {
+                return fmt.Errorf("failed to check foreign key %s.%s: %w", tableName, col, err)
+            }
+            if exists {
+                continue
+            }
+            if _, err := db.Exec(stmt); err != nil {
+                return fmt.Errorf("failed to add foreign key %s.%s: %w", tableName, col, err)
+            }
+            log.Printf("Added foreign key %s.%s -> %s.%s", tableName, col, fk.RefTable, fk.RefColumn)
+        }
+    }
+    return nil
+}
+
+// constraintExists reports whether a table has a constraint of the given name.
+// Only PostgreSQL adds foreign keys after loading, so current_schema() is safe.
+func constraintExists(db *DB, table, name string) (bool, error) {
+    var n int
+    err := db.QueryRow("SELECT COUNT(*) FROM information_schema.table_constraints WHERE table_schema = current_schema() AND table_name = ? AND constraint_name = ?", table, name).Scan(&n)
+    return n > 0, err
+}
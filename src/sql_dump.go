@@ -0,0 +1,273 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "compress/gzip"
+    "database/sql"
+    "database/sql/driver"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "log"
+    "math"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// sqlDump is an execer that writes statements to a file instead of running them.
+// Arguments are interpolated as MySQL literals, so the output is plain SQL.
+type sqlDump struct {
+    w io.Writer
+}
+
+// Exec writes one statement terminated by a semicolon
+func (d *sqlDump) Exec(query string, args ...interface{}) (sql.Result, error) {
+    stmt, err := interpolateSQL(query, args)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := io.WriteString(d.w, strings.TrimSpace(stmt)+";\n"); err != nil {
+        return nil, err
+    }
+    return driver.RowsAffected(0), nil
+}
+
+// interpolateSQL replaces each ? placeholder outside quotes with its argument as a literal
+func interpolateSQL(query string, args []interface{}) (string, error) {
+    var b strings.Builder
+    var quote byte
+    n := 0
+    for i := 0; i < len(query); i++ {
+        ch := query[i]
+        switch {
+        case quote != 0:
+            if ch == quote {
+                quote = 0
+            }
+        case ch == '`' || ch == '\'' || ch == '"':
+            quote = ch
+        case ch == '?':
+            if n >= len(args) {
+                return "", fmt.Errorf("too few arguments for query")
+            }
+            lit, err := sqlLiteral(args[n])
+            if err != nil {
+                return "", fmt.Errorf("argument %d: %w", n+1, err)
+            }
+            b.WriteString(lit)
+            n++
+            continue
+        }
+        b.WriteByte(ch)
+    }
+    if n != len(args) {
+        return "", fmt.Errorf("query has %d placeholders but %d arguments", n, len(args))
+    }
+    return b.String(), nil
+}
+
+// sqlLiteral formats a value the way the MySQL driver sends it
+func sqlLiteral(v interface{}) (string, error) {
+    switch v := v.(type) {
+    case nil:
+        return "NULL", nil
+    case string:
+        return quoteSQLString(v), nil
+    case []byte:
+        if v == nil {
+            return "NULL", nil
+        }
+        return "X'" + hex.EncodeToString(v) + "'", nil
+    case bool:
+        if v {
+            return "1", nil
+        }
+        return "0", nil
+    case float32:
+        return sqlLiteral(float64(v))
+    case float64:
+        if math.IsNaN(v) || math.IsInf(v, 0) {
+            return "", fmt.Errorf("%v has no SQL representation", v)
+        }
+        return strconv.FormatFloat(v, 'g', -1, 64), nil
+    case int8, int16, int32, int64, int, uint8, uint16, uint32, uint64, uint:
+        return fmt.Sprint(v), nil
+    default:
+        return "", fmt.Errorf("unsupported type %T", v)
+    }
+}
+
+// quoteSQLString quotes a string with backslash escapes
+func quoteSQLString(s string) string {
+    var b strings.Builder
+    b.WriteByte('\'')
+    for i := 0; i < len(s); i++ {
+        switch c := s[i]; c {
+        case 0:
+            b.WriteString(`\0`)
+        case '\n':
+            b.WriteString(`\n`)
+        case '\r':
+            b.WriteString(`\r`)
+        case 0x1a:
+            b.WriteString(`\Z`)
+        case '\'', '"', '\\':
+            b.WriteByte('\\')
+            b.WriteByte(c)
+        default:
+            b.WriteByte(c)
+        }
+    }
+    b.WriteByte('\'')
+    return b.String()
+}
+
+// dumpDialect resolves the MySQL flavour a dump is written for. Without a server
+// to ask, plain mysql means 5.7, whose upserts every version accepts.
+func dumpDialect(name string) (Dialect, error) {
+    d, err := dialectFor(name)
+    if err != nil {
+        return nil, err
+    }
+    if _, ok := d.(mysqlDialect); !ok {
+        return nil, fmt.Errorf("SQL dumps are written for MySQL or MariaDB, not %s", d.Name())
+    }
+    return d, nil
+}
+
+// DumpDBCs writes an SQL file for every meta, or for one DBC if name is set
+func DumpDBCs(dir, name string, dialect Dialect, gz, force bool, cfg *Config) error {
+    metas, err := filepath.Glob(filepath.Join(cfg.Paths.Meta, "*.meta.json"))
+    if err != nil {
+        return fmt.Errorf("failed to scan meta directory: %w", err)
+    }
+    if name != "" {
+        metas = []string{filepath.Join(cfg.Paths.Meta, name+".meta.json")}
+    }
+
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return fmt.Errorf("failed to create %s: %w", dir, err)
+    }
+
+    for _, metaPath := range metas {
+        if err := DumpDBC(dir, dialect, gz, force, cfg, metaPath); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// DumpDBC writes the statements ImportDBC would run for one DBC to <dir>/<table>.sql,
+// or <table>.sql.gz when gz is set
+func DumpDBC(dir string, dialect Dialect, gz, force bool, cfg *Config, metaPath string) error {
+    meta, err := LoadMeta(metaPath)
+    if err != nil {
+        return fmt.Errorf("failed to load meta %s: %w", metaPath, err)
+    }
+
+    tableName := resolveTableName(&meta, cfg)
+
+    if !dbcExists(cfg, meta.File) {
+        log.Printf("Skipping %s: DBC file does not exist", tableName)
+        return nil
+    }
+
+    dbc, err := LoadDBCFile(cfg, meta)
+    if err != nil {
+        return fmt.Errorf("failed to load DBC %s: %w", meta.File, err)
+    }
+
+    checkUniqueKeys(&dbc, tableName)
+
+    path := filepath.Join(dir, tableName+".sql")
+    if gz {
+        path += ".gz"
+    }
+    f, err := os.Create(path)
+    if err != nil {
+        return fmt.Errorf("failed to create %s: %w", path, err)
+    }
+    defer f.Close()
+
+    var w io.Writer = f
+    var zw *gzip.Writer
+    if gz {
+        zw = gzip.NewWriter(f)
+        w = zw
+    }
+
+    var fks []foreignKey
+    if cfg.Options.ForeignKeys {
+        fks = foreignKeys(cfg, tableName, dbc.Table.Schema)
+    }
+
+    if err := writeTableDump(w, dialect, tableName, &dbc, cfg.Options.FloatStorage, fks, force); err != nil {
+        return fmt.Errorf("failed to write %s: %w", path, err)
+    }
+
+    if zw != nil {
+        if err := zw.Close(); err != nil {
+            return fmt.Errorf("failed to write %s: %w", path, err)
+        }
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("failed to write %s: %w", path, err)
+    }
+
+    log.Printf("Wrote %s", path)
+    return nil
+}
+
+// writeTableDump writes the checksum entry, table, records and WDB2 header of one DBC
+func writeTableDump(w io.Writer, mysql Dialect, tableName string, dbc *DBCFile, floats string, fks []foreignKey, force bool) error {
+    d := &sqlDump{w: w}
+    fmt.Fprintf(w, "-- %s from %s, %d records\n", tableName, dbc.Table.Schema.Meta.File, dbc.Table.Len())
+
+    // dumps load in any order, so referenced tables may not exist yet
+    if len(fks) > 0 {
+        if _, err := d.Exec("SET foreign_key_checks = 0"); err != nil {
+            return err
+        }
+    }
+
+    if err := ensureChecksumTable(d, mysql); err != nil {
+        return err
+    }
+    if _, err := d.Exec("INSERT IGNORE INTO dbc_checksum (table_name, checksum) VALUES (?, 0)", tableName); err != nil {
+        return err
+    }
+
+    if force {
+        if _, err := d.Exec(mysql.DropTable(tableName)); err != nil {
+            return err
+        }
+    }
+    if err := createTable(d, mysql, tableName, dbc.Table.Schema, floats, fks); err != nil {
+        return err
+    }
+
+    if dbc.Table.Len() > 0 {
+        if _, err := d.Exec("START TRANSACTION"); err != nil {
+            return err
+        }
+        if err := insertRows(d, mysql, tableName, dbc, floats, fks); err != nil {
+            return err
+        }
+        if _, err := d.Exec("COMMIT"); err != nil {
+            return err
+        }
+    }
+
+    if dbc.Header.Format() == FormatWDB2 {
+        if err := storeHeader(d, mysql, tableName, dbc); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2025 DBCTool
+//
+// DBCTool is licensed under the MIT License.
+// See the LICENSE file for details.
+
+package main
+
+import (
+    "fmt"
+    "math"
+)
+
+// Column is a single scalar column of a table, named as in SQL.
+// Array fields expand to one column per element and Loc fields to 17 columns.
+type Column struct {
+    Name   string // expanded name, e.g. item_2 or name_enus
+    Type   string // scalar type: int8-int64, uint8-uint64, float or string
+    Field  int    // index of the owning field in MetaFile.Fields
+    Index  int    // array element within the field, starting at 0
+    Locale int    // Loc sub-column (0-16), -1 for non-Loc fields
+    Offset int    // byte offset within a record
+    Size   int    // byte size within a record
+}
+
+// Schema is the column layout of a meta, resolved once and shared by all rows
+type Schema struct {
+    Meta       *MetaFile
+    Columns    []Column
+    RecordSize int
+    FieldCount int
+
+    index map[string]int
+}
+
+// NewSchema expands the fields of a meta into columns
+func NewSchema(meta *MetaFile) (*Schema, error) {
+    s := &Schema{Meta: meta, index: map[string]int{}}
+    offset := 0
+    for fi, field := range meta.Fields {
+        repeat := int(field.Count)
+        if repeat == 0 {
+            repeat = 1
+        }
+
+        for j := 0; j < repeat; j++ {
+            name := field.Name
+            if field.Count > 1 {
+                name = fmt.Sprintf("%s_%d", field.Name, j+1)
+            }
+
+            if field.Type == "Loc" {
+                for k, lang := range locLangs {
+                    typ := "string"
+                    if k == len(locLangs)-1 {
+                        typ = "uint32"
+                    }
+                    s.add(Column{Name: name + "_" + lang, Type: typ, Field: fi, Index: j, Locale: k, Offset: offset, Size: 4})
+                    offset += 4
+                }
+                continue
+            }
+
+            size, err := fieldSize(field.Type)
+            if err != nil {
+                return nil, err
+            }
+            s.add(Column{Name: name, Type: field.Type, Field: fi, Index: j, Locale: -1, Offset: offset, Size: size})
+            offset += size
+        }
+    }
+    s.RecordSize = offset
+    s.FieldCount = len(s.Columns)
+    return s, nil
+}
+
+func (s *Schema) add(c Column) {
+    if _, dup := s.index[c.Name]; !dup {
+        s.index[c.Name] = len(s.Columns)
+    }
+    s.Columns = append(s.Columns, c)
+}
+
+// Lookup returns the position of a column by name
+func (s *Schema) Lookup(name string) (int, bool) {
+    i, ok := s.index[name]
+    return i, ok
+}
+
+// columnData holds the values of one column in a slice of its byte width
+type columnData struct {
+    u8  []uint8
+    u16 []uint16
+    u32 []uint32
+    u64 []uint64
+}
+
+// Table is a columnar in-memory table. Values are stored by width as raw bits
+// and interpreted through the column type; strings hold string block offsets.
+type Table struct {
+    Schema *Schema
+    rows   int
+    data   []columnData
+}
+
+// NewTable returns an empty table with room for capacity rows
+func NewTable(schema *Schema, capacity int) *Table {
+    t := &Table{Schema: schema, data: make([]columnData, len(schema.Columns))}
+    for i, c := range schema.Columns {
+        switch c.Size {
+        case 1:
+            t.data[i].u8 = make([]uint8, 0, capacity)
+        case 2:
+            t.data[i].u16 = make([]uint16, 0, capacity)
+        case 4:
+            t.data[i].u32 = make([]uint32, 0, capacity)
+        case 8:
+            t.data[i].u64 = make([]uint64, 0, capacity)
+        }
+    }
+    return t
+}
+
+// Len returns the number of rows
+func (t *Table) Len() int {
+    return t.rows
+}
+
+// AppendRow adds a zeroed row and returns its index
+func (t *Table) AppendRow() int {
+    for i := range t.data {
+        d := &t.data[i]
+        switch t.Schema.Columns[i].Size {
+        case 1:
+            d.u8 = append(d.u8, 0)
+        case 2:
+            d.u16 = append(d.u16, 0)
+        case 4:
+            d.u32 = append(d.u32, 0)
+        case 8:
+            d.u64 = append(d.u64, 0)
+        }
+    }
+    t.rows++
+    return t.rows - 1
+}
+
+// Raw returns the stored bits of a value, zero-extended
+func (t *Table) Raw(row, col int) uint64 {
+    d := &t.data[col]
+    switch t.Schema.Columns[col].Size {
+    case 1:
+        return uint64(d.u8[row])
+    case 2:
+        return uint64(d.u16[row])
+    case 4:
+        return uint64(d.u32[row])
+    default:
+        return d.u64[row]
+    }
+}
+
+// SetRaw stores the low bits of v that fit the column width
+func (t *Table) SetRaw(row, col int, v uint64) {
+    d := &t.data[col]
+    switch t.Schema.Columns[col].Size {
+    case 1:
+        d.u8[row] = uint8(v)
+    case 2:
+        d.u16[row] = uint16(v)
+    case 4:
+        d.u32[row] = uint32(v)
+    default:
+        d.u64[row] = v
+    }
+}
+
+// Int returns an integer value, sign-extended for signed types
+func (t *Table) Int(row, col int) int64 {
+    raw := t.Raw(row, col)
+    switch t.Schema.Columns[col].Type {
+    case "int8":
+        return int64(int8(raw))
+    case "int16":
+        return int64(int16(raw))
+    case "int32":
+        return int64(int32(raw))
+    default:
+        return int64(raw)
+    }
+}
+
+// Uint returns an unsigned integer value or string offset
+func (t *Table) Uint(row, col int) uint64 {
+    return uint64(t.Int(row, col))
+}
+
+// Float returns a float value
+func (t *Table) Float(row, col int) float32 {
+    return math.Float32frombits(uint32(t.Raw(row, col)))
+}
+
+// SetInt stores an integer value
+func (t *Table) SetInt(row, col int, v int64) {
+    t.SetRaw(row, col, uint64(v))
+}
+
+// SetFloat stores a float value
+func (t *Table) SetFloat(row, col int, v float32) {
+    t.SetRaw(row, col, uint64(math.Float32bits(v)))
+}
+
+// Value returns a value as its Go type; strings are returned as their uint32 offset
+func (t *Table) Value(row, col int) interface{} {
+    raw := t.Raw(row, col)
+    switch t.Schema.Columns[col].Type {
+    case "int8":
+        return int8(raw)
+    case "int16":
+        return int16(raw)
+    case "int32":
+        return int32(raw)
+    case "int64":
+        return int64(raw)
+    case "uint8":
+        return uint8(raw)
+    case "uint16":
+        return uint16(raw)
+    case "uint32", "string":
+        return uint32(raw)
+    case "float":
+        return math.Float32frombits(uint32(raw))
+    default:
+        return raw
+    }
+}